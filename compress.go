@@ -0,0 +1,68 @@
+package mylog
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// 压缩后的日志文件后缀
+const compressSuffix = ".gz"
+
+// 文件名(去掉.gz和日志扩展名后)是否以KeepSuffix结尾
+func (hook *logHook) hasKeepSuffix(fileName string) bool {
+	fileName = strings.TrimSuffix(fileName, compressSuffix)
+	if strings.HasSuffix(fileName, hook.LogConfig.KeepSuffix) {
+		return true
+	}
+	fileName = strings.TrimSuffix(fileName, hook.LogConfig.LogExt)
+	return strings.HasSuffix(fileName, hook.LogConfig.KeepSuffix)
+}
+
+// 使用gzip压缩文件为path.gz，成功后删除原文件，压缩文件保留原文件的修改时间。
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + compressSuffix + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	gz.Name = info.Name()
+	gz.ModTime = info.ModTime()
+	if _, err = io.Copy(gz, src); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	if err = dst.Sync(); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, path+compressSuffix); err != nil {
+		return err
+	}
+	_ = os.Chtimes(path+compressSuffix, info.ModTime(), info.ModTime())
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,78 @@
+package mylog
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_compressFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.log")
+	content := strings.Repeat("hello world\n", 100)
+	if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("original file still exists, err = %v", err)
+	}
+	f, err := os.Open(path + compressSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("decompressed content mismatch")
+	}
+}
+
+func TestCompressRotatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:      dir,
+		NoConsole:   true,
+		DateSplit:   true,
+		MaxLogSize:  512,
+		ErrSeparate: true,
+		Compress:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		logger.Error("0123456789012345678901234567890123456789")
+	}
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+	folders, _ := getFolderNamesInPath(dir)
+	if len(folders) != 1 {
+		t.Fatalf("folders = %v", folders)
+	}
+	files, _ := getFileNmaesInPath(filepath.Join(dir, folders[0]))
+	var plain, compressed int
+	for _, file := range files {
+		if strings.HasSuffix(file, compressSuffix) {
+			compressed++
+		} else {
+			plain++
+		}
+	}
+	// only the active segment stays uncompressed
+	if compressed == 0 || plain != 2 {
+		t.Errorf("files = %v", files)
+	}
+}
@@ -2,16 +2,20 @@ package mylog
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/doraemonkeys/doraemon"
-	mpmc "github.com/doraemonkeys/fast-mpmc"
 	myformatter "github.com/doraemonkeys/mylog/formatter"
 	"github.com/sirupsen/logrus"
 )
@@ -21,6 +25,9 @@ import (
 // After enabling the maximum retention days for logs, if the log folder path is not set, it defaults to this path.
 const DefaultSavePath = "./logs"
 
+// 按大小分割时文件名的时间格式
+const sizeSplitTimeFmt = "2006_01_02_150405"
+
 // logrus level
 const (
 	PanicLevel = "panic"
@@ -43,8 +50,25 @@ type LogConfig struct {
 	ErrSeparate bool
 	// Exclude error logs from normal log file (when errors are separated)
 	ErrNotInNormal bool
-	// Split logs by date (cannot be used with size split)
+	// Write the entries of the listed levels to extra log files, e.g. warn.log and debug.log,
+	// see Route. The files share the split and retention settings of the normal log file.
+	Routes []Route
+	// Split logs by date. When MaxLogSize is also set, a new segment (2006_01_02.1.log, 2006_01_02.2.log, ...)
+	// is started whenever the file of the day reaches MaxLogSize.
 	DateSplit bool
+	// Rotation period when DateSplit is enabled (daily, hourly, weekly, monthly, minutes), default is daily.
+	RotationPeriod RotationPeriod
+	// Rotation interval in minutes, only used when RotationPeriod is RotateMinutes.
+	RotationMinutes int
+	// Template of log file names without extension, it overrides the default naming rules.
+	// Supported placeholders: {time} (the layout depends on the split mode), {time:2006-01-02} (custom layout),
+	// {name} (DefaultLogName), {suffix} (LogFileNameSuffix), {hostname}, {pid} and {seq} (segment sequence).
+	// {time} is required when splitting logs. Error log files get an extra _error suffix, route files an _<name> suffix.
+	LogFileNameTemplate string
+	// Maintain a symlink with this name in LogDir pointing at the active log file (e.g. current.log),
+	// and one with an _error suffix (current_error.log) pointing at the active error log file when errors are separated.
+	// The links are replaced atomically by rename whenever the active files change.
+	CurrentLink string
 	// Disable file output for logs
 	LogFileDisable bool
 	// Disable console output for logs
@@ -63,6 +87,27 @@ type LogConfig struct {
 	DisableWriterBuffer bool
 	// Write buffer size, default is 4096 bytes
 	WriterBufferSize int
+	// Maximum number of entries waiting in the async write queue (unless DisableWriterBuffer), default is 8192.
+	// Error logs separated by ErrSeparate are written through the same queue.
+	QueueSize int
+	// What to do with an entry when the queue is full (block, drop_newest, drop_oldest, drop_below_level),
+	// default is block. Panic and fatal entries are never dropped. Dropped entries are counted, see GetQueueStats.
+	OverflowPolicy OverflowPolicy
+	// Entries less severe than this level are dropped when the queue is full and OverflowPolicy is drop_below_level,
+	// the other entries wait for room. Default is warn.
+	OverflowLevel string
+	// Maximum time an entry stays in the write buffer, default is 1 second. The buffer is also flushed
+	// whenever the queue is empty.
+	FlushInterval time.Duration
+	// Commit the log files to stable storage (fsync) after every SyncBytes bytes written, 0 disables it.
+	SyncBytes int64
+	// Commit the log files to stable storage (fsync) at most SyncInterval after a write, 0 disables it.
+	SyncInterval time.Duration
+	// Write and commit to stable storage every entry at Error level and above before the logging call returns.
+	SyncOnError bool
+	// Check the active log files every interval and reopen them when they were moved or deleted
+	// (compared by device and inode), e.g. by logrotate with the create option. 0 disables the check.
+	ReopenCheckInterval time.Duration
 	// Output in JSON format
 	JSONFormat bool
 	// Disable color output
@@ -72,26 +117,85 @@ type LogConfig struct {
 	// PadLevelText Adds padding the level text so that all the levels
 	// output at the same length PadLevelText is a superset of the DisableLevelTruncation option
 	PadLevelText bool
-	// Split logs by size in bytes (cannot be used with date split)
+	// Split logs by size in bytes (combined with DateSplit, rotates on whichever comes first).
+	// Without DateSplit the file names contain the creation time in seconds, a file created
+	// in the same second as an existing one gets a sequence suffix, e.g. 2006_01_02_150405.1.log.
 	MaxLogSize int64
 	// Maximum retention days for logs. After enabling this, if the log folder path is not set, it defaults to DefaultSavePath.
-	// Please do not place other files in the log folder, otherwise they may be deleted.
+	// Retention only deletes files created by this library, that is files whose names match the naming rules
+	// and LogExt (or RetentionPatterns), in LogDir and its date folders.
 	MaxKeepDays int
+	// Maximum total size in bytes of all log files in LogDir. The oldest files are deleted first after every rotation.
+	// After enabling this, if the log folder path is not set, it defaults to DefaultSavePath.
+	MaxTotalSize int64
+	// Maximum number of rotated log files to keep in LogDir (the active files are not counted).
+	// After enabling this, if the log folder path is not set, it defaults to DefaultSavePath.
+	MaxBackups int
+	// Compress rotated log files with gzip in the background (files with the keep suffix are never compressed)
+	Compress bool
+	// Suffix of log files and folders that should never be deleted or compressed, default is keep.
+	// e.g. 2006_01_02_150405_keep.log or 2006_01_02_keep
+	KeepSuffix string
+	// Extra file name patterns (filepath.Match syntax, e.g. "app-*.log") that retention treats as log files.
+	// Use it to migrate directories with files created by an older naming configuration.
+	RetentionPatterns []string
 	// Log file extension (default is .log)
 	LogExt string
-	// Log level (panic, fatal, error, warn, info, debug, trace)
+	// Log level (panic, fatal, error, warn, info, debug, trace), default is info.
+	// NewLogger treats an unknown level as info, Validate rejects it.
 	LogLevel string
+	// Minimum levels by caller, e.g. [{"Prefix": "github.com/foo/bar/db", "Level": "debug"}].
+	// Prefix is matched against the package path and the file path of the caller, the longest match wins.
+	// Entries from callers without a matching rule use LogLevel. Requires caller information (DisableCaller must be false).
+	// logrus has a single level, so a rule more verbose than LogLevel lowers the level of the logger to it:
+	// entries of that level are then built everywhere (caller lookup, other hooks and the formatter call)
+	// before the cached rule of their call site drops them, and IsLevelEnabled reports the level as enabled.
+	// Rules that are not more verbose than LogLevel keep the level of the logger and cost nothing for other callers.
+	LevelRules []LevelRule
+	// Sampling per call site and level like zap: the first SampleInitial entries in every SampleInterval
+	// (default is 1 second) are logged, then every SampleThereafter-th entry (0 drops the rest).
+	// Disabled when SampleInitial is 0. Fatal and panic entries are never sampled or rate limited.
+	SampleInitial    int
+	SampleThereafter int
+	SampleInterval   time.Duration
+	// Token bucket per call site and level: at most RateLimit entries per second with bursts of RateBurst
+	// (default is RateLimit rounded up). Disabled when RateLimit is 0.
+	RateLimit float64
+	RateBurst int
+	// Interval of the summary entries reporting how many entries each call site dropped by sampling
+	// and rate limiting, default is 1 minute. A summary is logged at the level of the dropped entries.
+	DropSummaryInterval time.Duration
+	// Collapse consecutive entries with the same level, message and caller in the log files (syslog style):
+	// the first entry is written, the repeats within DedupWindow are held back and replaced by
+	// a "last message repeated N times" line. Fatal and panic entries are never held back.
+	DedupFile bool
+	// Same as DedupFile for the console output, the console and the files are collapsed independently.
+	DedupConsole bool
+	// Window of DedupFile and DedupConsole, default is 30 seconds.
+	DedupWindow time.Duration
 	// Time zone
 	TimeLocation *time.Location
+	// Static fields added to every entry, e.g. {"service": "api", "env": "prod", "version": "1.0.0"}.
+	// Fields set on the entry (e.g. by WithField) are not overwritten.
+	Fields map[string]interface{}
+	// Dynamic fields evaluated for every entry, e.g. DefaultFieldProviders() for hostname and pid,
+	// GoroutinesProvider() and BuildInfoProvider(). They can not be loaded from files or environment variables.
+	FieldProviders map[string]FieldProvider
+	// Extra outputs of the entries besides the console and the log files, e.g. NewWriterSink(os.Stderr).
+	// Every sink has its own level, formatter and buffer and is written by its own goroutine,
+	// a failing or slow sink drops its own entries and never blocks the log files.
+	// Sinks are closed by Close and Shutdown. They can not be changed by UpdateConfig
+	// or loaded from files or environment variables.
+	Sinks []SinkConfig
 	// Key for appending to each log entry
 	key string
 	// Value for appending to each log entry
 	value interface{}
-	// Suffix for log files that should not be deleted
-	keepSuffix string
 }
 
 // SetKeyValue sets the key and value for appending to each log entry.
+//
+// Deprecated: Use Fields, which supports more than one field.
 func (c *LogConfig) SetKeyValue(key string, value interface{}) {
 	c.key = key
 	c.value = value
@@ -100,7 +204,9 @@ func (c *LogConfig) SetKeyValue(key string, value interface{}) {
 type logHook struct {
 	// 写入文件的操作是线程安全的
 	ErrWriter *doraemon.LazyFileWriter
-	// 写入文件的操作是线程安全的
+	// 各路由的文件，与routes一一对应
+	RouteWriters []*doraemon.LazyFileWriter
+	// 写入文件的操作是线程安全的(开启缓冲时为OtherBufWriter的底层文件，不直接写入)
 	OtherWriter *os.File
 	// bufio 并发不安全，只在一个goroutine中写入
 	OtherBufWriter *bufio.Writer
@@ -111,16 +217,62 @@ type logHook struct {
 
 	// LastBufferWroteTime time.Time
 
-	bufferQueue *mpmc.FastMpmc[[]byte]
-	LogConfig   LogConfig
+	bufferQueue *lineQueue
+	// 上次fsync之后写入的字节数
+	unsynced atomic.Int64
+	// 上次刷新缓冲的时间，只在bufferFlusher中使用
+	lastFlush time.Time
+	LogConfig LogConfig
 	// 2006_01_02
 	FileDate string
-	// byte,仅在SizeSplit>0时有效
-	LogSize int64
-	// 2006_01_02
+	// byte,仅在SizeSplit>0时有效。writeLine持有读锁时累加，所以使用原子操作
+	LogSize atomic.Int64
+	// 当天的分段序号(0表示2006_01_02.log,1表示2006_01_02.1.log)。仅按大小分割时为同一秒内创建的文件的序号
+	FileSeq int
+	// 分割周期对应的时间格式，默认2006_01_02(同时作为分离错误日志时的文件夹名)
 	dateFmt string
 	// 2006_01_02_150405(按大小分割时使用)
 	dateFmt2 string
+	// 分割周期(未按日期分割时为按天，仅用于文件夹)
+	period RotationPeriod
+	// 仅按大小分割时，下次打开文件不续写最新的文件(Rotate)
+	newSegment bool
+	// 当前日志文件名中的时间(按日期分割时为周期的开始时间)
+	fileTime time.Time
+	// 普通日志和错误日志的文件名模板
+	commonTmpl *fileNameTemplate
+	errorTmpl  *fileNameTemplate
+	// LogConfig.Routes及其文件名模板
+	routes []logRoute
+
+	// 用于停止后台goroutine(bufferFlusher、deleteOldLogTimer)
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// 分割后的后台任务(压缩、按总大小和数量清理)，串行执行
+	splitWg sync.WaitGroup
+	splitMu sync.Mutex
+	// 开启NoConsole之前logger的输出，用于UpdateConfig关闭NoConsole时恢复
+	consoleOut io.Writer
+	// 按调用位置的级别规则，没有规则时为nil
+	levelRules atomic.Pointer[levelRules]
+	// 没有匹配的规则时使用的级别
+	baseLevel atomic.Uint32
+	// 采样和限流，未开启时为nil
+	sampler atomic.Pointer[sampler]
+	// 文件和控制台的重复日志合并，未开启时为nil
+	fileDedup    atomic.Pointer[deduper]
+	consoleDedup atomic.Pointer[deduper]
+	// 日志文件输出
+	file fileSink
+	// LogConfig.Sinks，创建后不再修改
+	sinks []*sinkRunner
+	// 用于记录丢弃统计
+	logger *logrus.Logger
+	// 关闭后不再写入文件，读写需持有WriterLock
+	closed    bool
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // InitGlobalLogger initializes the global logger.The global logger is the default logger of logrus.
@@ -138,67 +290,22 @@ func NewLogger(config LogConfig) (*logrus.Logger, error) {
 	return logger, nil
 }
 
-var logDirsMap = make(map[string]bool)
-
-func initlLog(logger *logrus.Logger, config LogConfig) error {
-
-	var level logrus.Level = PraseLevel(config.LogLevel)
-	//fmt.Println("level:", level)
-
-	if !config.DisableCaller {
-		logger.SetReportCaller(true) //开启调用者信息
-	}
+var (
+	logDirsMap = make(map[string]bool)
+	logDirsMu  sync.Mutex
+)
 
-	logger.SetLevel(level) //设置最低的Level
+func releaseLogDir(logDir string) {
+	logDirsMu.Lock()
+	delete(logDirsMap, filepath.Clean(logDir))
+	logDirsMu.Unlock()
+}
 
+// 填充默认值并检查保留规则
+func normalizeConfig(config *LogConfig) error {
 	if config.TimestampFormat == "" {
 		config.TimestampFormat = "2006-01-02 15:04:05.000"
 	}
-
-	// if config.NoTimestamp {
-	// 	formatter.DisableTimestamp = true
-	// }
-
-	var formatter logrus.Formatter
-	if config.JSONFormat {
-		formatter = &logrus.JSONFormatter{
-			TimestampFormat:  config.TimestampFormat, //时间戳格式
-			DisableTimestamp: config.NoTimestamp,     //开启时间戳
-			CallerPrettyfier: func(f *runtime.Frame) (string, string) {
-				return "", ""
-			},
-		}
-	} else {
-		formatter = &myformatter.TextFormatter{
-			TimestampFormat:        config.TimestampFormat, //时间戳格式
-			FullTimestamp:          true,
-			DisableTimestamp:       config.NoTimestamp,    //开启时间戳
-			ForceColors:            !config.DisableColors, //开启颜色
-			DisableLevelTruncation: config.DisableLevelTruncation,
-			PadLevelText:           config.PadLevelText,
-			// CallerPrettyfier: func(f *runtime.Frame) (string, string) {
-			// 	//返回shortfile,funcname,linenum
-			// 	//main.go:main:12
-			// 	shortFile := f.File
-			// 	if strings.Contains(f.File, "/") {
-			// 		shortFile = f.File[strings.LastIndex(f.File, "/")+1:]
-			// 	}
-			// 	return "", fmt.Sprintf("%s:%s():%d:", shortFile, f.Function, f.Line)
-			// },
-
-			// 禁用自带的file和func字段，
-			CallerPrettyfier: func(f *runtime.Frame) (string, string) {
-				return "", ""
-			},
-		}
-	}
-
-	logger.SetFormatter(formatter)
-
-	if config.NoConsole {
-		logger.SetOutput(io.Discard)
-	}
-
 	if config.LogExt == "" {
 		config.LogExt = ".log"
 	}
@@ -211,69 +318,271 @@ func initlLog(logger *logrus.Logger, config LogConfig) error {
 	if config.DefaultLogName == "" {
 		config.DefaultLogName = "default"
 	}
-	if config.MaxKeepDays > 0 && config.LogDir == "" {
+	if (config.MaxKeepDays > 0 || config.MaxTotalSize > 0 || config.MaxBackups > 0) && config.LogDir == "" {
 		config.LogDir = DefaultSavePath
 	}
+	if config.KeepSuffix == "" {
+		config.KeepSuffix = "keep"
+	}
+	// 复制一份，防止调用者之后修改
+	config.Fields = maps.Clone(config.Fields)
+	config.FieldProviders = maps.Clone(config.FieldProviders)
+	for key, provider := range config.FieldProviders {
+		if provider == nil {
+			return fmt.Errorf("field provider %q is nil", key)
+		}
+	}
+	for _, pattern := range config.RetentionPatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid retention pattern %q: %v", pattern, err)
+		}
+	}
+	if err := validateOverflow(*config); err != nil {
+		return err
+	}
+	config.Sinks = slices.Clone(config.Sinks)
+	if err := validateSinks(config.Sinks); err != nil {
+		return err
+	}
+	config.Routes = slices.Clone(config.Routes)
+	if err := validateRoutes(config.Routes, config.ErrSeparate); err != nil {
+		return err
+	}
+	return validateLevelRules(config.LevelRules)
+}
 
-	if logDirsMap[filepath.Clean(config.LogDir)] {
-		return fmt.Errorf("logDir:%s has been used", config.LogDir)
-	} else {
-		logDirsMap[filepath.Clean(config.LogDir)] = true
+// 占用日志目录，同一个目录只能被一个logger使用
+func acquireLogDir(logDir string) error {
+	logDirsMu.Lock()
+	defer logDirsMu.Unlock()
+	if logDirsMap[filepath.Clean(logDir)] {
+		return fmt.Errorf("logDir:%s has been used", logDir)
 	}
+	logDirsMap[filepath.Clean(logDir)] = true
+	return nil
+}
+
+func newFormatter(config LogConfig) logrus.Formatter {
+	if config.JSONFormat {
+		return &logrus.JSONFormatter{
+			TimestampFormat:  config.TimestampFormat, //时间戳格式
+			DisableTimestamp: config.NoTimestamp,     //开启时间戳
+			CallerPrettyfier: func(f *runtime.Frame) (string, string) {
+				return "", ""
+			},
+		}
+	}
+	return &myformatter.TextFormatter{
+		TimestampFormat:        config.TimestampFormat, //时间戳格式
+		FullTimestamp:          true,
+		DisableTimestamp:       config.NoTimestamp,    //开启时间戳
+		ForceColors:            !config.DisableColors, //开启颜色
+		DisableLevelTruncation: config.DisableLevelTruncation,
+		PadLevelText:           config.PadLevelText,
+		// CallerPrettyfier: func(f *runtime.Frame) (string, string) {
+		// 	//返回shortfile,funcname,linenum
+		// 	//main.go:main:12
+		// 	shortFile := f.File
+		// 	if strings.Contains(f.File, "/") {
+		// 		shortFile = f.File[strings.LastIndex(f.File, "/")+1:]
+		// 	}
+		// 	return "", fmt.Sprintf("%s:%s():%d:", shortFile, f.Function, f.Line)
+		// },
 
-	config.keepSuffix = "keep"
+		// 禁用自带的file和func字段，
+		CallerPrettyfier: func(f *runtime.Frame) (string, string) {
+			return "", ""
+		},
+	}
+}
 
-	hook := &logHook{}
-	hook.bufferQueue = mpmc.NewFastMpmc[[]byte](10)
-	hook.dateFmt = "2006_01_02"
-	hook.dateFmt2 = "2006_01_02_150405"
-	hook.FileDate = time.Now().In(config.TimeLocation).Format(hook.dateFmt)
-	hook.LogSize = 0
+func initlLog(logger *logrus.Logger, config LogConfig) error {
+	if err := normalizeConfig(&config); err != nil {
+		return err
+	}
+	if err := acquireLogDir(config.LogDir); err != nil {
+		return err
+	}
+
+	hook := &logHook{LogConfig: config}
+	hook.file = fileSink{hook: hook}
+	hook.bufferQueue = newLineQueue(config)
+	hook.dateFmt2 = sizeSplitTimeFmt
+	if err := hook.initFileNameTemplates(); err != nil {
+		releaseLogDir(config.LogDir)
+		return err
+	}
+	hook.FileDate = hook.periodKey(time.Now())
+	hook.LogSize.Store(0)
 	hook.WriterLock = &sync.RWMutex{}
-	hook.LogConfig = config
 	hook.WriterBufferSize = config.WriterBufferSize
 	if hook.WriterBufferSize <= 0 {
 		hook.WriterBufferSize = 4096
 	}
-
-	//添加hook
-	logger.AddHook(hook)
+	hook.ctx, hook.cancel = context.WithCancel(context.Background())
 
 	err := hook.updateNewLogPathAndFile()
 	if err != nil {
+		hook.cancel()
+		releaseLogDir(config.LogDir)
 		return fmt.Errorf("updateNewLogPathAndFile err:%v", err)
 	}
-	if config.MaxKeepDays > 0 {
-		go hook.deleteOldLogTimer()
+
+	if !config.DisableCaller {
+		logger.SetReportCaller(true) //开启调用者信息
 	}
-	if !config.DisableWriterBuffer && !config.LogFileDisable {
-		go hook.bufferFlusher()
+	hook.logger = logger
+	hook.levelRules.Store(newLevelRules(config.LevelRules))
+	hook.sampler.Store(newSampler(config))
+	hook.fileDedup.Store(newDeduper(config.DedupFile, config.DedupWindow))
+	hook.consoleDedup.Store(newDeduper(config.DedupConsole, config.DedupWindow))
+	hook.setLevel(logger, PraseLevel(config.LogLevel)) //设置最低的Level
+	hook.setFormatter(logger, newFormatter(config))
+	hook.consoleOut = logger.Out
+	if config.NoConsole {
+		logger.SetOutput(io.Discard)
 	}
+
+	// 在添加hook之前启动，Fire和GetSinkStats读取hook.sinks时不需要加锁
+	for i, sc := range config.Sinks {
+		if setter, ok := sc.Sink.(logDirSetter); ok {
+			setter.setLogDir(config.LogDir)
+		}
+		r := newSinkRunner(sc, i)
+		hook.sinks = append(hook.sinks, r)
+		go r.run()
+	}
+
+	// 运行时可能通过UpdateConfig开启保留策略和缓冲，所以总是启动
+	hook.wg.Add(6)
+	go hook.deleteOldLogTimer()
+	go hook.bufferFlusher()
+	go hook.dropSummaryTimer()
+	go hook.dedupFlusher()
+	go hook.syncTimer()
+	go hook.movedFileChecker()
+
+	//添加hook
+	logger.AddHook(hook)
+	addManagedHook(hook)
 	return nil
 }
 
 func (hook *logHook) bufferFlusher() {
+	defer hook.wg.Done()
 	for {
-		lines := hook.bufferQueue.WaitPopAll()
+		select {
+		case <-hook.ctx.Done():
+			return
+		case <-hook.bufferQueue.signal:
+		}
 		hook.WriterLock.RLock()
-		for i := 0; i < len(*lines); i++ {
-			_, err := hook.OtherBufWriter.Write((*lines)[i])
-			if err != nil {
-				fmt.Fprintln(os.Stderr, "bufferFlusher Write err:", err)
-			}
+		if hook.closed {
+			hook.WriterLock.RUnlock()
+			return
+		}
+		hook.drainQueue()
+		// 队列为空或者超过FlushInterval时刷新缓冲
+		flushInterval := hook.LogConfig.FlushInterval
+		if flushInterval <= 0 {
+			flushInterval = time.Second
 		}
-		if hook.bufferQueue.LenNoLock() == 0 {
+		now := time.Now()
+		if hook.OtherBufWriter != nil && (hook.bufferQueue.len() == 0 || now.Sub(hook.lastFlush) >= flushInterval) {
 			err := hook.OtherBufWriter.Flush()
 			if err != nil {
 				fmt.Fprintln(os.Stderr, "flushBuffer err:", err)
 			}
+			hook.lastFlush = now
 		}
+		hook.syncIfNeeded()
 		hook.WriterLock.RUnlock()
-		hook.bufferQueue.RecycleBuffer(lines)
 	}
 }
 
-// Deprecated: You don't need to call this function now.
+// 写入队列中的日志，必须持有WriterLock(读锁或写锁)调用，且同一时刻只能有一个goroutine调用
+func (hook *logHook) drainQueue() {
+	lines := hook.bufferQueue.popAll()
+	if lines == nil {
+		return
+	}
+	hook.writeBufferLines(lines)
+	hook.bufferQueue.recycle(lines)
+}
+
+// 必须持有WriterLock(读锁或写锁)调用，且同一时刻只能有一个goroutine调用
+func (hook *logHook) writeBufferLines(lines []queuedLine) {
+	var w io.Writer
+	switch {
+	case hook.OtherBufWriter != nil:
+		w = hook.OtherBufWriter
+	case hook.OtherWriter != nil:
+		// UpdateConfig关闭了缓冲，队列中剩余的日志直接写入文件
+		w = hook.OtherWriter
+	}
+	for i := 0; i < len(lines); i++ {
+		err := hook.writeTo(w, lines[i].level, lines[i].line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "bufferFlusher Write err:", err)
+		}
+	}
+}
+
+// findLogHooks returns all mylog hooks attached to the logger.
+func findLogHooks(logger *logrus.Logger) []*logHook {
+	if logger == nil {
+		return nil
+	}
+	var found []*logHook
+	var seen = make(map[*logHook]bool)
+	for _, hooks := range logger.Hooks {
+		for _, hook := range hooks {
+			if h, ok := hook.(*logHook); ok && h != nil && !seen[h] {
+				seen[h] = true
+				found = append(found, h)
+			}
+		}
+	}
+	return found
+}
+
+// Close is like Shutdown with a background context.
+func Close(logger *logrus.Logger) error {
+	return Shutdown(context.Background(), logger)
+}
+
+// Shutdown drains the write queue, flushes and fsyncs the log files, closes them,
+// stops the background goroutines and releases the log directory so that it can be used again.
+// The hook is removed from the logger, console output is not affected.
+//
+// It is safe to call Shutdown more than once. If ctx is done before the background
+// goroutines exit, the files are still flushed and closed and ctx.Err() is returned.
+func Shutdown(ctx context.Context, logger *logrus.Logger) error {
+	hooks := findLogHooks(logger)
+	if len(hooks) == 0 {
+		return nil
+	}
+	var newHooks = make(logrus.LevelHooks)
+	for level, levelHooks := range logger.Hooks {
+		for _, hook := range levelHooks {
+			if h, ok := hook.(*logHook); ok && h != nil {
+				continue
+			}
+			newHooks[level] = append(newHooks[level], hook)
+		}
+	}
+	logger.ReplaceHooks(newHooks)
+
+	var errs []error
+	for _, hook := range hooks {
+		if err := hook.shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Deprecated: You don't need to call this function now. Use Sync to commit the logs to stable storage.
 func FlushBuf(logger *logrus.Logger) error {
 	if logger == nil {
 		return nil
@@ -294,16 +603,8 @@ func FlushBuf(logger *logrus.Logger) error {
 				if logHook == nil {
 					continue
 				}
-				if logHook.OtherBufWriter != nil {
-					logHook.WriterLock.Lock()
-					if logHook.OtherBufWriter != nil && logHook.OtherBufWriter.Buffered() > 0 {
-						err := logHook.OtherBufWriter.Flush()
-						if err != nil {
-							logHook.WriterLock.Unlock()
-							return err
-						}
-					}
-					logHook.WriterLock.Unlock()
+				if err := logHook.file.Flush(); err != nil {
+					return err
 				}
 			}
 		}
@@ -338,3 +639,19 @@ func DeleteOldLog(logger *logrus.Logger, n int) error {
 	}
 	return nil
 }
+
+// RetentionDryRun returns the log files and date folders that the configured retention policies
+// (MaxKeepDays, MaxTotalSize and MaxBackups) would delete now, and why. Nothing is deleted.
+func RetentionDryRun(logger *logrus.Logger) ([]LogSegment, error) {
+	var plan []LogSegment
+	for _, hook := range findLogHooks(logger) {
+		hook.splitMu.Lock()
+		segments, err := hook.planRetention(hook.configRetentionPolicy())
+		hook.splitMu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, segments...)
+	}
+	return plan, nil
+}
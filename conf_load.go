@@ -0,0 +1,368 @@
+package mylog
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultEnvPrefix is the default prefix of the environment variables read by ConfigFromEnv.
+const DefaultEnvPrefix = "MYLOG"
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	locationType = reflect.TypeOf((*time.Location)(nil))
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// 以字节为单位的字段，可以使用100MB这样的大小
+var byteSizeFields = map[string]bool{
+	"MaxLogSize":       true,
+	"MaxTotalSize":     true,
+	"WriterBufferSize": true,
+	"SyncBytes":        true,
+}
+
+// LoadConfig reads a LogConfig from a JSON file and validates it.
+//
+// Keys are the field names of LogConfig (case-insensitive). Besides plain JSON values,
+// TimeLocation accepts an IANA name such as "Asia/Shanghai", sizes in bytes (MaxLogSize, MaxTotalSize,
+// WriterBufferSize and SyncBytes) accept strings such as "100MB" and durations accept strings such as "1m30s".
+func LoadConfig(path string) (LogConfig, error) {
+	var config LogConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return config, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	v := reflect.ValueOf(&config).Elem()
+	for key, value := range raw {
+		field, name, ok := configField(v, func(name string) bool { return strings.EqualFold(name, key) })
+		if !ok {
+			return config, fmt.Errorf("unknown config key %q in %s", key, path)
+		}
+		if err := setConfigFieldJSON(field, name, value); err != nil {
+			return config, fmt.Errorf("config key %q: %w", key, err)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// ConfigFromEnv reads a LogConfig from environment variables and validates it.
+//
+// The variable of a field is the prefix (DefaultEnvPrefix if empty) followed by the field name
+// in upper snake case, e.g. MYLOG_LOG_DIR, MYLOG_MAX_LOG_SIZE=100MB, MYLOG_TIME_LOCATION=Asia/Shanghai.
+// Slices are comma-separated.
+func ConfigFromEnv(prefix string) (LogConfig, error) {
+	var config LogConfig
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	prefix = strings.TrimSuffix(prefix, "_") + "_"
+	v := reflect.ValueOf(&config).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		name := t.Field(i).Name
+		envName := prefix + toUpperSnake(name)
+		value, ok := os.LookupEnv(envName)
+		if !ok {
+			continue
+		}
+		if err := setConfigFieldString(v.Field(i), name, value); err != nil {
+			return config, fmt.Errorf("%s: %w", envName, err)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// Validate checks the config for invalid values and conflicting options.
+// All problems found are returned together.
+func (c LogConfig) Validate() error {
+	var errs []error
+	if c.LogLevel != "" {
+		if _, err := parseLevelStrict(c.LogLevel); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.ErrNotInNormal && !c.ErrSeparate {
+		errs = append(errs, errors.New("ErrNotInNormal requires ErrSeparate"))
+	}
+	if c.RotationPeriod != "" && !c.DateSplit {
+		errs = append(errs, errors.New("RotationPeriod requires DateSplit"))
+	}
+	if c.MaxLogSize < 0 {
+		errs = append(errs, fmt.Errorf("MaxLogSize must not be negative: %d", c.MaxLogSize))
+	}
+	if c.MaxTotalSize < 0 {
+		errs = append(errs, fmt.Errorf("MaxTotalSize must not be negative: %d", c.MaxTotalSize))
+	}
+	if c.MaxKeepDays < 0 {
+		errs = append(errs, fmt.Errorf("MaxKeepDays must not be negative: %d", c.MaxKeepDays))
+	}
+	if c.MaxBackups < 0 {
+		errs = append(errs, fmt.Errorf("MaxBackups must not be negative: %d", c.MaxBackups))
+	}
+	if c.SampleInitial < 0 || c.SampleThereafter < 0 || c.SampleInterval < 0 {
+		errs = append(errs, errors.New("SampleInitial, SampleThereafter and SampleInterval must not be negative"))
+	}
+	if c.SampleThereafter > 0 && c.SampleInitial == 0 {
+		errs = append(errs, errors.New("SampleThereafter requires SampleInitial"))
+	}
+	if c.RateLimit < 0 || c.RateBurst < 0 || c.DropSummaryInterval < 0 {
+		errs = append(errs, errors.New("RateLimit, RateBurst and DropSummaryInterval must not be negative"))
+	}
+	if c.DedupWindow < 0 {
+		errs = append(errs, fmt.Errorf("DedupWindow must not be negative: %v", c.DedupWindow))
+	}
+	if c.RateBurst > 0 && c.RateLimit == 0 {
+		errs = append(errs, errors.New("RateBurst requires RateLimit"))
+	}
+	if err := validateOverflow(c); err != nil {
+		errs = append(errs, err)
+	}
+	if c.FlushInterval < 0 || c.SyncBytes < 0 || c.SyncInterval < 0 {
+		errs = append(errs, errors.New("FlushInterval, SyncBytes and SyncInterval must not be negative"))
+	}
+	if c.ReopenCheckInterval < 0 {
+		errs = append(errs, fmt.Errorf("ReopenCheckInterval must not be negative: %v", c.ReopenCheckInterval))
+	}
+	if c.WriterBufferSize < 0 {
+		errs = append(errs, fmt.Errorf("WriterBufferSize must not be negative: %d", c.WriterBufferSize))
+	}
+	if strings.ContainsAny(c.CurrentLink, `/\`) {
+		errs = append(errs, fmt.Errorf("CurrentLink must not contain path separators: %q", c.CurrentLink))
+	}
+	if strings.ContainsAny(c.LogExt, `/\`) {
+		errs = append(errs, fmt.Errorf("LogExt must not contain path separators: %q", c.LogExt))
+	}
+	if c.LogFileDisable && (c.ErrSeparate || c.DateSplit || c.MaxLogSize > 0) {
+		errs = append(errs, errors.New("LogFileDisable conflicts with ErrSeparate, DateSplit and MaxLogSize"))
+	}
+	for _, pattern := range c.RetentionPatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid retention pattern %q: %v", pattern, err))
+		}
+	}
+	for key, provider := range c.FieldProviders {
+		if provider == nil {
+			errs = append(errs, fmt.Errorf("field provider %q is nil", key))
+		}
+	}
+	if err := validateRoutes(c.Routes, c.ErrSeparate); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateSinks(c.Sinks); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateLevelRules(c.LevelRules); err != nil {
+		errs = append(errs, err)
+	}
+	if len(c.LevelRules) > 0 && c.DisableCaller {
+		errs = append(errs, errors.New("LevelRules requires caller information, DisableCaller must be false"))
+	}
+
+	// 检查分割周期和文件名模板
+	if c.LogExt == "" {
+		c.LogExt = ".log"
+	}
+	if c.TimeLocation == nil {
+		c.TimeLocation = time.Local
+	}
+	hook := &logHook{LogConfig: c, dateFmt2: sizeSplitTimeFmt}
+	if err := hook.initFileNameTemplates(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// 按名称查找LogConfig中可以设置的字段
+func configField(v reflect.Value, match func(name string) bool) (reflect.Value, string, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() && match(t.Field(i).Name) {
+			return v.Field(i), t.Field(i).Name, true
+		}
+	}
+	return reflect.Value{}, "", false
+}
+
+func setConfigFieldJSON(field reflect.Value, name string, value json.RawMessage) error {
+	if string(value) == "null" {
+		return nil
+	}
+	// 字符串形式的时区、大小和时间间隔
+	if len(value) > 0 && value[0] == '"' && field.Kind() != reflect.String {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return err
+		}
+		return setConfigFieldString(field, name, s)
+	}
+	if field.Type() == locationType {
+		return errors.New("TimeLocation must be an IANA time zone name")
+	}
+	return json.Unmarshal(value, field.Addr().Interface())
+}
+
+// 将字符串形式的值设置到字段
+func setConfigFieldString(field reflect.Value, name, value string) error {
+	value = strings.TrimSpace(value)
+	switch field.Type() {
+	case locationType:
+		loc, err := time.LoadLocation(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(loc))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		var err error
+		if byteSizeFields[name] {
+			n, err = parseSize(value)
+		} else {
+			n, err = strconv.ParseInt(value, 10, 64)
+		}
+		if err != nil {
+			return err
+		}
+		if field.OverflowInt(n) {
+			return fmt.Errorf("value %s overflows %s", value, field.Type())
+		}
+		field.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		elem := field.Type().Elem()
+		isText := reflect.PointerTo(elem).Implements(textUnmarshalerType)
+		if elem.Kind() != reflect.String && !isText {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		s := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if isText {
+				// 如 LevelRules: prefix=level
+				if err := s.Index(i).Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(item)); err != nil {
+					return err
+				}
+				continue
+			}
+			s.Index(i).SetString(item)
+		}
+		field.Set(s)
+	case reflect.Map:
+		// 如 Fields: service=api,env=prod
+		if field.Type().Key().Kind() != reflect.String || field.Type().Elem().Kind() != reflect.Interface {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		m := reflect.MakeMap(field.Type())
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			k, v, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("invalid item %q, want key=value", item)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)), reflect.ValueOf(strings.TrimSpace(v)))
+		}
+		field.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// 解析大小，如 100MB、1.5GB、4096(字节)，单位以1024为进制，不区分大小写
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	upper := strings.ToUpper(s)
+	units := []struct {
+		suffix string
+		size   float64
+	}{
+		{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+		{"B", 1},
+	}
+	var multiplier float64 = 1
+	for _, unit := range units {
+		if strings.HasSuffix(upper, unit.suffix) {
+			multiplier = unit.size
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return int64(n * multiplier), nil
+}
+
+// LogDir -> LOG_DIR, JSONFormat -> JSON_FORMAT
+func toUpperSnake(name string) string {
+	runes := []rune(name)
+	var buf strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				buf.WriteByte('_')
+			}
+		}
+		buf.WriteRune(unicode.ToUpper(r))
+	}
+	return buf.String()
+}
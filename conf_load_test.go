@@ -0,0 +1,171 @@
+package mylog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func Test_parseSize(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{"4096", 4096, false},
+		{"100MB", 100 << 20, false},
+		{"100mb", 100 << 20, false},
+		{"1.5 GB", 3 << 29, false},
+		{"2KiB", 2048, false},
+		{"10k", 10240, false},
+		{"12B", 12, false},
+		{"MB", 0, true},
+		{"-1MB", 0, true},
+		{"ten", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d, err %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func Test_toUpperSnake(t *testing.T) {
+	tests := map[string]string{
+		"LogDir":                 "LOG_DIR",
+		"JSONFormat":             "JSON_FORMAT",
+		"ShowShortFileInConsole": "SHOW_SHORT_FILE_IN_CONSOLE",
+		"MaxKeepDays":            "MAX_KEEP_DAYS",
+		"LogExt":                 "LOG_EXT",
+	}
+	for in, want := range tests {
+		if got := toUpperSnake(in); got != want {
+			t.Errorf("toUpperSnake(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.json")
+	content := `{
+		"logDir": "./logs",
+		"DateSplit": true,
+		"MaxLogSize": "100MB",
+		"MaxTotalSize": 1048576,
+		"SyncBytes": "1MB",
+		"TimeLocation": "Asia/Shanghai",
+		"LogLevel": "debug",
+		"RetentionPatterns": ["app-*.log"]
+	}`
+	if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.LogDir != "./logs" || !config.DateSplit || config.MaxLogSize != 100<<20 ||
+		config.MaxTotalSize != 1<<20 || config.SyncBytes != 1<<20 || config.TimeLocation.String() != "Asia/Shanghai" ||
+		config.LogLevel != "debug" || len(config.RetentionPatterns) != 1 {
+		t.Errorf("config = %+v", config)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"LogDri": "./logs"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "LogDri") {
+		t.Errorf("LoadConfig() err = %v, want unknown key error", err)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("APP_LOG_DIR", "/var/log/app")
+	t.Setenv("APP_ERR_SEPARATE", "true")
+	t.Setenv("APP_MAX_LOG_SIZE", "10MB")
+	t.Setenv("APP_SYNC_BYTES", "64KB")
+	t.Setenv("APP_JSON_FORMAT", "1")
+	t.Setenv("APP_TIME_LOCATION", "UTC")
+	t.Setenv("APP_RETENTION_PATTERNS", "a-*.log, b-*.log")
+	t.Setenv("APP_LEVEL_RULES", "github.com/foo/db=debug, /src/vendor/=error")
+	t.Setenv("APP_ROUTES", "warn:warn|error,debug:debug|trace:exclusive")
+	config, err := ConfigFromEnv("APP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.LogDir != "/var/log/app" || !config.ErrSeparate || config.MaxLogSize != 10<<20 || config.SyncBytes != 64<<10 ||
+		!config.JSONFormat || config.TimeLocation.String() != "UTC" || len(config.RetentionPatterns) != 2 {
+		t.Errorf("config = %+v", config)
+	}
+	wantRules := []LevelRule{{Prefix: "github.com/foo/db", Level: "debug"}, {Prefix: "/src/vendor/", Level: "error"}}
+	if !slices.Equal(config.LevelRules, wantRules) {
+		t.Errorf("LevelRules = %+v, want %+v", config.LevelRules, wantRules)
+	}
+	if len(config.Routes) != 2 || config.Routes[0].Name != "warn" || !config.Routes[1].Exclusive ||
+		!slices.Equal(config.Routes[1].Levels, []string{"debug", "trace"}) {
+		t.Errorf("Routes = %+v", config.Routes)
+	}
+
+	t.Setenv("APP_MAX_KEEP_DAYS", "seven")
+	if _, err := ConfigFromEnv("APP"); err == nil || !strings.Contains(err.Error(), "APP_MAX_KEEP_DAYS") {
+		t.Errorf("ConfigFromEnv() err = %v", err)
+	}
+}
+
+func TestLogConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  LogConfig
+		wantErr string
+	}{
+		{"empty", LogConfig{}, ""},
+		{"date and size", LogConfig{DateSplit: true, MaxLogSize: 1024}, ""},
+		{"unknown level", LogConfig{LogLevel: "verbose"}, "unknown log level"},
+		{"err not in normal", LogConfig{ErrNotInNormal: true}, "ErrNotInNormal requires ErrSeparate"},
+		{"period without date split", LogConfig{RotationPeriod: RotateHourly}, "RotationPeriod requires DateSplit"},
+		{"unknown period", LogConfig{DateSplit: true, RotationPeriod: "yearly"}, "unknown rotation period"},
+		{"minutes", LogConfig{DateSplit: true, RotationPeriod: RotateMinutes}, "RotationMinutes"},
+		{"template", LogConfig{DateSplit: true, LogFileNameTemplate: "{name}"}, "{time}"},
+		{"negative", LogConfig{MaxKeepDays: -1}, "MaxKeepDays"},
+		{"level rule", LogConfig{LevelRules: []LevelRule{{Prefix: "db", Level: "loud"}}}, "level rule \"db\""},
+		{"level rule without caller", LogConfig{DisableCaller: true, LevelRules: []LevelRule{{Prefix: "db", Level: "debug"}}}, "DisableCaller"},
+		{"overflow policy", LogConfig{OverflowPolicy: "drop_all"}, "OverflowPolicy"},
+		{"overflow level", LogConfig{OverflowPolicy: OverflowDropBelowLevel, OverflowLevel: "loud"}, "OverflowLevel"},
+		{"nil sink", LogConfig{Sinks: []SinkConfig{{Name: "net"}}}, "sink \"net\": Sink is nil"},
+		{"sink level", LogConfig{Sinks: []SinkConfig{{Sink: NewWriterSink(io.Discard), Level: "loud"}}}, "sink \"sink0\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() err = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewLoggerUnknownLevel(t *testing.T) {
+	config := LogConfig{LogFileDisable: true, NoConsole: true, LogLevel: "wran"}
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatalf("NewLogger() err = %v, want an unknown level treated as info", err)
+	}
+	defer Close(logger)
+	if logger.GetLevel() != logrus.InfoLevel {
+		t.Errorf("level = %v, want info", logger.GetLevel())
+	}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "unknown log level") {
+		t.Errorf("Validate() err = %v, want unknown log level", err)
+	}
+}
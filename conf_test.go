@@ -0,0 +1,60 @@
+package mylog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClose(t *testing.T) {
+	dir := t.TempDir()
+	config := LogConfig{
+		LogDir:      dir,
+		NoConsole:   true,
+		ErrSeparate: true,
+	}
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		logger.Info("hello")
+	}
+	logger.Error("oops")
+
+	if err := Close(logger); err != nil {
+		t.Fatalf("Close() err = %v", err)
+	}
+	if err := Close(logger); err != nil {
+		t.Fatalf("second Close() err = %v", err)
+	}
+	if len(findLogHooks(logger)) != 0 {
+		t.Fatal("hook is still attached after Close")
+	}
+
+	folders, err := getFolderNamesInPath(dir)
+	if err != nil || len(folders) != 1 {
+		t.Fatalf("folders = %v, err = %v", folders, err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, folders[0], "default.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(content), "hello"); got != 100 {
+		t.Errorf("got %d lines in normal log, want 100", got)
+	}
+	content, err = os.ReadFile(filepath.Join(dir, folders[0], "default_error.log"))
+	if err != nil || !strings.Contains(string(content), "oops") {
+		t.Errorf("error log = %q, err = %v", content, err)
+	}
+
+	// the log dir is released and can be used again
+	logger2, err := NewLogger(config)
+	if err != nil {
+		t.Fatalf("reuse logDir err = %v", err)
+	}
+	if err := Close(logger2); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,204 @@
+package mylog
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Field names of the built-in context extractors.
+const (
+	TraceIDField   = "trace_id"
+	SpanIDField    = "span_id"
+	RequestIDField = "request_id"
+)
+
+// ContextExtractor adds the fields extracted from ctx by calling add.
+type ContextExtractor func(ctx context.Context, add func(key string, value interface{}))
+
+type namedExtractor struct {
+	name      string
+	extractor ContextExtractor
+}
+
+var (
+	// 写时复制，读取时不加锁
+	contextExtractors   atomic.Pointer[[]namedExtractor]
+	contextExtractorsMu sync.Mutex
+)
+
+func init() {
+	RegisterContextExtractor("trace", extractTraceContext)
+	RegisterContextExtractor("request_id", extractRequestID)
+}
+
+// RegisterContextExtractor registers an extractor that adds fields from the context of log entries
+// (logrus Entry.Context, see WithContext). An extractor with the same name is replaced.
+// The built-in extractors are "trace" (trace_id and span_id from ContextWithTraceparent)
+// and "request_id" (request_id from ContextWithRequestID).
+func RegisterContextExtractor(name string, extractor ContextExtractor) {
+	contextExtractorsMu.Lock()
+	defer contextExtractorsMu.Unlock()
+	var extractors []namedExtractor
+	if old := contextExtractors.Load(); old != nil {
+		extractors = append(extractors, *old...)
+	}
+	for i := range extractors {
+		if extractors[i].name == name {
+			extractors[i].extractor = extractor
+			contextExtractors.Store(&extractors)
+			return
+		}
+	}
+	extractors = append(extractors, namedExtractor{name: name, extractor: extractor})
+	contextExtractors.Store(&extractors)
+}
+
+// UnregisterContextExtractor removes the extractor registered with name.
+func UnregisterContextExtractor(name string) {
+	contextExtractorsMu.Lock()
+	defer contextExtractorsMu.Unlock()
+	old := contextExtractors.Load()
+	if old == nil {
+		return
+	}
+	var extractors []namedExtractor
+	for _, e := range *old {
+		if e.name != name {
+			extractors = append(extractors, e)
+		}
+	}
+	contextExtractors.Store(&extractors)
+}
+
+// RegisterContextKey registers an extractor named field that adds ctx.Value(key) as field when it is not nil.
+func RegisterContextKey(key interface{}, field string) {
+	RegisterContextExtractor(field, func(ctx context.Context, add func(string, interface{})) {
+		if value := ctx.Value(key); value != nil {
+			add(field, value)
+		}
+	})
+}
+
+// ContextFields returns the fields extracted from ctx by the registered extractors.
+func ContextFields(ctx context.Context) map[string]interface{} {
+	var fields map[string]interface{}
+	extractContext(ctx, func(key string, value interface{}) {
+		if fields == nil {
+			fields = make(map[string]interface{})
+		}
+		fields[key] = value
+	})
+	return fields
+}
+
+func extractContext(ctx context.Context, add func(key string, value interface{})) {
+	if ctx == nil {
+		return
+	}
+	extractors := contextExtractors.Load()
+	if extractors == nil {
+		return
+	}
+	for _, e := range *extractors {
+		e.extractor(ctx, add)
+	}
+}
+
+// 添加从entry.Context中提取的字段，不覆盖entry中已有的字段
+func addContextFields(entry *logrus.Entry) {
+	extractContext(entry.Context, func(key string, value interface{}) {
+		if _, ok := entry.Data[key]; !ok {
+			entry.Data[key] = value
+		}
+	})
+}
+
+// WithContext returns an entry of the standard logger with ctx, the fields extracted from ctx
+// are added when the entry is logged. Use logger.WithContext(ctx) for other loggers.
+func WithContext(ctx context.Context) *logrus.Entry {
+	return logrus.WithContext(ctx)
+}
+
+type contextKey int
+
+const (
+	traceContextKey contextKey = iota
+	requestIDKey
+)
+
+type traceContext struct {
+	traceID string
+	spanID  string
+}
+
+// ContextWithTraceparent returns a copy of ctx with the trace and span IDs of a W3C traceparent header,
+// e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01. Invalid headers are ignored.
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	tc, ok := parseTraceparent(traceparent)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, traceContextKey, tc)
+}
+
+// ContextWithTraceID returns a copy of ctx with the trace and span IDs, spanID may be empty.
+func ContextWithTraceID(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceContextKey, traceContext{traceID: traceID, spanID: spanID})
+}
+
+// ContextWithRequestID returns a copy of ctx with the request ID.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// 版本-trace id(32位十六进制)-span id(16位十六进制)-flags
+func parseTraceparent(s string) (traceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return traceContext{}, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return traceContext{}, false
+	}
+	if !isHex(traceID, 32) || !isHex(spanID, 16) || !isHex(flags, 2) {
+		return traceContext{}, false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return traceContext{}, false
+	}
+	return traceContext{traceID: traceID, spanID: spanID}, true
+}
+
+// 小写十六进制
+func isHex(s string, n int) bool {
+	if len(s) != n || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func extractTraceContext(ctx context.Context, add func(string, interface{})) {
+	tc, ok := ctx.Value(traceContextKey).(traceContext)
+	if !ok {
+		return
+	}
+	if tc.traceID != "" {
+		add(TraceIDField, tc.traceID)
+	}
+	if tc.spanID != "" {
+		add(SpanIDField, tc.spanID)
+	}
+}
+
+func extractRequestID(ctx context.Context, add func(string, interface{})) {
+	if id, ok := ctx.Value(requestIDKey).(string); ok && id != "" {
+		add(RequestIDField, id)
+	}
+}
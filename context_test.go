@@ -0,0 +1,76 @@
+package mylog
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_parseTraceparent(t *testing.T) {
+	tests := []struct {
+		s      string
+		want   traceContext
+		wantOk bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceContext{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"}, true},
+		{" 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00 ", traceContext{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"}, true},
+		// 未来的版本可以有更多字段
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", traceContext{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"}, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", traceContext{}, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceContext{}, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", traceContext{}, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", traceContext{}, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", traceContext{}, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", traceContext{}, false},
+		{"", traceContext{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseTraceparent(tt.s)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("parseTraceparent(%q) = %v, %v, want %v, %v", tt.s, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestContextFields(t *testing.T) {
+	type tenantKey struct{}
+	RegisterContextKey(tenantKey{}, "tenant")
+	t.Cleanup(func() { UnregisterContextExtractor("tenant") })
+
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		JSONFormat:          true,
+		DisableWriterBuffer: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx = ContextWithRequestID(ctx, "req-1")
+	ctx = context.WithValue(ctx, tenantKey{}, "acme")
+	logger.WithContext(ctx).Info("hello")
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "default.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(content, &entry); err != nil {
+		t.Fatalf("%q: %v", content, err)
+	}
+	if entry[TraceIDField] != "4bf92f3577b34da6a3ce929d0e0e4736" || entry[SpanIDField] != "00f067aa0ba902b7" ||
+		entry[RequestIDField] != "req-1" || entry["tenant"] != "acme" {
+		t.Errorf("entry = %v", entry)
+	}
+
+	if fields := ContextFields(context.Background()); len(fields) != 0 {
+		t.Errorf("ContextFields(empty) = %v", fields)
+	}
+}
@@ -0,0 +1,136 @@
+package mylog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 只输出到控制台的entry(控制台的重复统计)，hook不写入文件
+type consoleOnlyContextKey struct{}
+
+func isConsoleOnly(entry *logrus.Entry) bool {
+	return entry.Context != nil && entry.Context.Value(consoleOnlyContextKey{}) != nil
+}
+
+type dedupKey struct {
+	level logrus.Level
+	msg   string
+	pc    uintptr
+}
+
+// 合并连续重复的日志(syslog风格)，控制台和文件各有一个
+type deduper struct {
+	mu     sync.Mutex
+	window time.Duration
+	last   dedupKey
+	valid  bool
+	// 最后一次输出的时间
+	since time.Time
+	// 未输出的重复次数
+	repeated int
+}
+
+func newDeduper(enabled bool, window time.Duration) *deduper {
+	if !enabled {
+		return nil
+	}
+	if window <= 0 {
+		window = 30 * time.Second
+	}
+	return &deduper{window: window}
+}
+
+func dedupChanged(old, config LogConfig) bool {
+	return old.DedupFile != config.DedupFile ||
+		old.DedupConsole != config.DedupConsole ||
+		old.DedupWindow != config.DedupWindow
+}
+
+// 返回是否丢弃，以及输出entry之前需要输出的上一条日志的重复次数。fatal和panic不会被丢弃。
+func (d *deduper) check(entry *logrus.Entry, now time.Time) (suppress bool, level logrus.Level, repeated int) {
+	key := dedupKey{level: entry.Level, msg: entry.Message}
+	if entry.Caller != nil {
+		key.pc = entry.Caller.PC
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.valid && key == d.last && now.Sub(d.since) < d.window && entry.Level > logrus.FatalLevel {
+		d.repeated++
+		return true, 0, 0
+	}
+	level, repeated = d.last.level, d.repeated
+	d.last, d.valid, d.since, d.repeated = key, true, now, 0
+	return false, level, repeated
+}
+
+// 返回超过窗口(force为true时不检查窗口)仍未输出的重复次数，之后相同的日志会重新输出
+func (d *deduper) expire(now time.Time, force bool) (logrus.Level, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.repeated == 0 || (!force && now.Sub(d.since) < d.window) {
+		return 0, 0
+	}
+	level, repeated := d.last.level, d.repeated
+	d.valid, d.repeated = false, 0
+	return level, repeated
+}
+
+func (hook *logHook) repeatedEntry(level logrus.Level, repeated int) *logrus.Entry {
+	entry := logrus.NewEntry(hook.logger)
+	entry.Time = time.Now()
+	entry.Level = level
+	entry.Message = fmt.Sprintf("last message repeated %d times", repeated)
+	return entry
+}
+
+// 向文件写入重复统计
+func (hook *logHook) writeRepeated(level logrus.Level, repeated int) {
+	line, err := hook.format(hook.repeatedEntry(level, repeated))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read entry, %v", err)
+		return
+	}
+	_ = hook.writeLine(level, eliminateColor(line))
+}
+
+// 输出超过窗口仍未输出的重复统计，force为true时输出所有
+func (hook *logHook) flushRepeated(force bool) {
+	now := time.Now()
+	if d := hook.fileDedup.Load(); d != nil {
+		if level, repeated := d.expire(now, force); repeated > 0 {
+			hook.writeRepeated(level, repeated)
+		}
+	}
+	if d := hook.consoleDedup.Load(); d != nil {
+		if level, repeated := d.expire(now, force); repeated > 0 {
+			ctx := context.WithValue(context.Background(), consoleOnlyContextKey{}, true)
+			hook.logger.WithContext(ctx).Logf(level, "last message repeated %d times", repeated)
+		}
+	}
+}
+
+func (hook *logHook) dedupFlusher() {
+	defer hook.wg.Done()
+	timer := time.NewTimer(time.Second)
+	defer timer.Stop()
+	for {
+		interval := time.Second
+		for _, d := range []*deduper{hook.fileDedup.Load(), hook.consoleDedup.Load()} {
+			if d != nil && d.window < interval {
+				interval = d.window
+			}
+		}
+		timer.Reset(interval)
+		select {
+		case <-hook.ctx.Done():
+			return
+		case <-timer.C:
+		}
+		hook.flushRepeated(false)
+	}
+}
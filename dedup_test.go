@@ -0,0 +1,95 @@
+package mylog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func Test_deduper(t *testing.T) {
+	now := time.Now()
+	caller := &runtime.Frame{PC: 1}
+	entry := &logrus.Entry{Level: logrus.WarnLevel, Message: "disk full", Caller: caller}
+	d := newDeduper(true, time.Minute)
+
+	if suppress, _, repeated := d.check(entry, now); suppress || repeated != 0 {
+		t.Fatalf("first entry: suppress = %v, repeated = %d", suppress, repeated)
+	}
+	for i := 0; i < 3; i++ {
+		if suppress, _, _ := d.check(entry, now.Add(time.Second)); !suppress {
+			t.Fatal("repeated entry should be suppressed")
+		}
+	}
+	// 不同调用位置不算重复
+	other := &logrus.Entry{Level: logrus.WarnLevel, Message: "disk full", Caller: &runtime.Frame{PC: 2}}
+	suppress, level, repeated := d.check(other, now.Add(2*time.Second))
+	if suppress || level != logrus.WarnLevel || repeated != 3 {
+		t.Errorf("suppress = %v, level = %v, repeated = %d", suppress, level, repeated)
+	}
+
+	d.check(other, now.Add(3*time.Second))
+	if _, repeated := d.expire(now.Add(3*time.Second), false); repeated != 0 {
+		t.Errorf("expired within the window: %d", repeated)
+	}
+	if _, repeated := d.expire(now.Add(time.Hour), false); repeated != 1 {
+		t.Errorf("expired repeated = %d, want 1", repeated)
+	}
+	// 过期后相同的日志重新输出
+	if suppress, _, _ := d.check(other, now.Add(time.Hour)); suppress {
+		t.Error("entry after expiry should be written")
+	}
+	fatal := &logrus.Entry{Level: logrus.FatalLevel, Message: "bye"}
+	d.check(fatal, now)
+	if suppress, _, _ := d.check(fatal, now); suppress {
+		t.Error("fatal entries should never be suppressed")
+	}
+}
+
+func TestDedup(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:              dir,
+		DisableWriterBuffer: true,
+		DedupFile:           true,
+		DedupConsole:        true,
+		DedupWindow:         time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var console bytes.Buffer
+	logger.SetOutput(&console)
+
+	for i := 0; i < 5; i++ {
+		logger.Warn("connection refused")
+	}
+	for i := 0; i < 2; i++ {
+		logger.Info("reconnected")
+	}
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "default.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, out := range map[string]string{"file": string(content), "console": console.String()} {
+		if n := strings.Count(out, "connection refused"); n != 1 {
+			t.Errorf("%s: %d entries of connection refused, want 1:\n%s", name, n, out)
+		}
+		if !strings.Contains(out, "last message repeated 4 times") {
+			t.Errorf("%s: missing repeated count:\n%s", name, out)
+		}
+		// 关闭时输出未输出的重复统计
+		if !strings.Contains(out, "last message repeated 1 times") {
+			t.Errorf("%s: pending repeated count not flushed on close:\n%s", name, out)
+		}
+	}
+}
@@ -10,14 +10,75 @@
   1. 其它为默认配置则会在目录生成文件2006_01_02.log(设置日志文件名无效)
 
 三、设置了按大小分割
-  1. 其它为默认配置则会在目录生成文件2006_01_02_150405.log(设置日志文件名无效)
+  1. 其它为默认配置则会在目录生成文件2006_01_02_150405.log(设置日志文件名无效)，
+     同一秒内创建的文件依次为2006_01_02_150405.1.log、2006_01_02_150405.2.log...
 
-四、其他
+四、同时设置按日期分割和按大小分割
+  1. 按日期或大小分割，以先达到者为准。
+  2. 其它为默认配置则会在目录生成文件2006_01_02.log，当天文件大小超过限制后依次生成2006_01_02.1.log、2006_01_02.2.log...
+  3. 程序重启后会继续写入当天最新的分段文件。
+
+五、分割周期与文件名模板
+  1. 按日期分割时可以通过RotationPeriod设置分割周期(hourly、weekly、monthly，或minutes配合RotationMinutes)，
+     文件名与分离错误日志时的文件夹名的时间格式随周期变化，如按小时分割为2006_01_02_15.log。
+  2. 设置LogFileNameTemplate可以自定义文件名，如 {name}-{hostname}-{time}，错误日志文件名会再加上_error后缀。
+     分割日志时模板必须包含{time}，程序通过模板从文件名中读取时间。
+
+六、压缩
+  1. 设置Compress后，分割日志时被关闭的日志文件(包括_error文件和日期文件夹中的文件)会在后台使用gzip压缩为.gz文件，
+     压缩文件保留原文件的修改时间，过期后同样会被删除。文件名以keep结尾的文件不会被压缩。
+
+七、按总大小和数量清理
+  1. 设置MaxTotalSize或MaxBackups后，每次分割日志后(以及每24小时)会统计LogDir中的日志文件和日期文件夹中的文件，
+     从最旧的文件开始删除，直到总大小和文件数量都在限制内。
+  2. 正在写入的文件计入总大小但不会被删除，以keep结尾的文件和文件夹不计入也不会被删除。
+
+八、其他
   1. 文件夹设置名称为log的创建时间而不加上最后修改时间是因为怕程序运行崩溃后，最后修改时间没有被添加。
      这样在设置了最大保存天数的情况下，会不太好处理。
   2. 设置了最大保存天数后，会在程序启动时启动一个goroutine来删除过期的日志文件(24小时检查一次)。
-     若有不想被删除的日志文件，可以在文件名后加上keep，如：2006_01_02_150405_keep.log，或者
+     日志文件和文件夹的创建时间从名称中解析(使用TimeLocation时区)，无法识别的名称使用最后修改时间。
+     可以使用RetentionDryRun查看将被删除的日志及原因。
+     若有不想被删除的日志文件，可以在文件名后加上keep(可通过KeepSuffix修改)，如：2006_01_02_150405_keep.log，或者
      在文件夹名后加上keep，如：2006_01_02_keep。
+  3. 清理日志时只会删除本库创建的文件，即文件名符合命名规则(或文件名模板)且扩展名为LogExt的文件，以及日期文件夹。
+     迁移旧的日志目录时，可以通过RetentionPatterns指定旧文件名的匹配规则，如 app-*.log。
+  4. 可以通过UpdateConfig在运行时修改配置(或使用WatchConfigFile监视配置文件)，NewLogger不接受的配置不会生效(WatchConfigFile还会拒绝未通过Validate的配置文件)。
+     修改LogDir、分割方式、文件名等配置时会关闭当前文件并打开新的文件，与分割日志相同。
+  5. NewLevelHandler返回的http.Handler可以查看和修改日志级别(支持logrus和ZapBuilder创建的logger)，
+     设置ttl后到期自动恢复为原来的级别。
+  6. LevelRules可以按调用者的包路径或文件路径前缀设置级别，如只输出某个包的debug日志。
+     设置后logger的级别为所有规则中最低的级别，由hook按调用位置过滤(控制台输出同样在格式化前过滤)，匹配结果按调用位置缓存。
+  7. Fields为每条日志添加固定字段，FieldProviders为每条日志添加动态字段(如DefaultFieldProviders中的hostname和pid、
+     GoroutinesProvider、BuildInfoProvider)，不会覆盖WithField设置的字段。ZapBuilder的Fields和FieldProviders输出相同的字段。
+  8. 使用WithContext(ctx)或logger.WithContext(ctx)记录日志时，会通过RegisterContextExtractor注册的提取器从ctx中提取字段，
+     内置trace_id、span_id(ContextWithTraceparent)和request_id(ContextWithRequestID)，RegisterContextKey可以添加自定义的key。
+     zap使用zap.WithContext(logger, ctx)。
+  9. SampleInitial、SampleThereafter按调用位置和级别采样(每个SampleInterval内记录前N条，之后每M条记录一条)，
+     RateLimit、RateBurst按调用位置和级别限流(令牌桶)，在格式化之前丢弃，fatal和panic不会被丢弃。
+     每隔DropSummaryInterval会以被丢弃日志的级别记录各调用位置丢弃的数量。
+  10. DedupFile、DedupConsole分别合并文件和控制台中连续重复(级别、消息、调用位置相同)的日志，
+     DedupWindow内的重复只记录一次，之后输出"last message repeated N times"，关闭时输出未输出的重复统计。
+  11. 开启缓冲时日志(包括单独输出的错误日志)先加入容量为QueueSize的队列，由后台goroutine写入文件，
+     队列满时按OverflowPolicy阻塞或丢弃，GetQueueStats返回队列长度和丢弃的数量。
+  12. FlushInterval为缓冲中日志的最长停留时间，SyncBytes、SyncInterval、SyncOnError按写入量、时间或错误日志fsync，
+     Sync(logger)写入队列和缓冲中的日志并fsync。
+  13. logger.Fatal和logrus.Exit退出前会通过SyncAll刷新所有logger(请使用logrus.Exit代替os.Exit)，
+     HandleSignals在收到SIGINT、SIGTERM时刷新后退出，defer RecoverAndLog()记录panic和堆栈，刷新后重新panic。
+  14. Rotate(logger)按大小分割的方式开始新文件，Reopen(logger)重新打开当前路径的文件(兼容logrotate的create模式)，
+     ReopenOnSignal、RotateOnSignal绑定信号(如SIGHUP、SIGUSR1)，ReopenCheckInterval定期按设备号和inode检查文件是否被移动或删除。
+  15. CurrentLink在LogDir中维护指向当前日志文件的符号链接(如current.log和current_error.log)，
+     启动和每次分割时通过重命名原子地更新。
+  16. Routes按级别把日志写入额外的文件(如2006_01_02_warn.log)，Exclusive的路由不再写入普通日志文件，
+     路由的文件与普通日志文件一起分割、压缩和清理。
+  17. Sinks添加控制台和日志文件以外的输出(实现Sink接口，或NewWriterSink)，每个Sink有独立的级别、formatter和缓冲，
+     在各自的goroutine中写入，写入慢或失败时只丢弃该Sink的日志，不会阻塞日志文件。Close和Shutdown时关闭。
+  18. NewSyslogSink通过udp、tcp、unix发送RFC 5424或RFC 3164格式的syslog，RFC 5424中字段(包括FILE、FUNC)作为结构化数据，
+     tcp默认按长度分帧，断开后按退避时间重连。
+  19. NewTCPSink按行发送与日志文件格式相同的日志，端点不可用时写入LogDir/spool(有大小上限，满时丢弃最旧的日志)，
+     重连后按顺序发送(包括上次运行留下的日志)，至少发送一次。
+  20. HTTPExporter按数量、大小和时间批量POST日志(JSON lines、Loki push、Elasticsearch _bulk)，支持gzip，
+     失败时按指数退避重试，缓冲大小有上限(满时丢弃最旧的批次)，Shutdown(ctx)时在ctx内发送剩余的日志。
 
 */
 package mylog
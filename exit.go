@@ -0,0 +1,142 @@
+package mylog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// 所有未关闭的hook，退出前刷新
+	managedHooks   = make(map[*logHook]struct{})
+	managedHooksMu sync.Mutex
+
+	registerExitHandlerOnce sync.Once
+	// 测试时替换
+	exitFunc = os.Exit
+)
+
+func addManagedHook(hook *logHook) {
+	managedHooksMu.Lock()
+	managedHooks[hook] = struct{}{}
+	managedHooksMu.Unlock()
+	// logger.Fatal和logrus.Exit退出前刷新所有logger
+	registerExitHandlerOnce.Do(func() {
+		logrus.RegisterExitHandler(func() {
+			if err := SyncAll(); err != nil {
+				fmt.Fprintln(os.Stderr, "mylog: sync err:", err)
+			}
+		})
+	})
+}
+
+func removeManagedHook(hook *logHook) {
+	managedHooksMu.Lock()
+	delete(managedHooks, hook)
+	managedHooksMu.Unlock()
+}
+
+func managedHookList() []*logHook {
+	managedHooksMu.Lock()
+	defer managedHooksMu.Unlock()
+	hooks := make([]*logHook, 0, len(managedHooks))
+	for hook := range managedHooks {
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+// SyncAll is like Sync for every logger created by mylog that is not closed.
+//
+// It runs as a logrus exit handler, so logger.Fatal and logrus.Exit flush every logger before exiting.
+// Use logrus.Exit instead of os.Exit to keep the buffered logs.
+func SyncAll() error {
+	var errs []error
+	for _, hook := range managedHookList() {
+		if err := hook.sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HandleSignals flushes and fsyncs every logger created by mylog when one of the signals
+// (SIGINT and SIGTERM by default) is received, then exits with status 128+signal number.
+// It is opt-in since it replaces the default behavior of the signals, call stop to restore it.
+func HandleSignals(signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-ch:
+			if err := SyncAll(); err != nil {
+				fmt.Fprintln(os.Stderr, "mylog: sync err:", err)
+			}
+			code := 1
+			if s, ok := sig.(syscall.Signal); ok {
+				code = 128 + int(s)
+			}
+			exitFunc(code)
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// RecoverAndLog logs the recovered panic with its stack to every logger created by mylog
+// (the standard logger if there is none), flushes and fsyncs them, then panics again with the same value.
+// It must be deferred directly:
+//
+//	defer mylog.RecoverAndLog()
+func RecoverAndLog() {
+	if r := recover(); r != nil {
+		logPanic(r)
+		panic(r)
+	}
+}
+
+// RecoverAndExit is like RecoverAndLog but exits with code instead of panicking again.
+//
+//	defer mylog.RecoverAndExit(2)
+func RecoverAndExit(code int) {
+	if r := recover(); r != nil {
+		logPanic(r)
+		exitFunc(code)
+	}
+}
+
+func logPanic(r interface{}) {
+	stack := string(debug.Stack())
+	var loggers []*logrus.Logger
+	for _, hook := range managedHookList() {
+		if hook.logger != nil && !slices.Contains(loggers, hook.logger) {
+			loggers = append(loggers, hook.logger)
+		}
+	}
+	if len(loggers) == 0 {
+		loggers = append(loggers, logrus.StandardLogger())
+	}
+	for _, logger := range loggers {
+		logger.WithField("stack", stack).Errorf("panic: %v", r)
+	}
+	if err := SyncAll(); err != nil {
+		fmt.Fprintln(os.Stderr, "mylog: sync err:", err)
+	}
+}
@@ -0,0 +1,83 @@
+package mylog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 缓冲很大，只有刷新后日志才会写入文件
+func newBufferedLogger(t *testing.T) (*logrus.Logger, string) {
+	t.Helper()
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:           dir,
+		NoConsole:        true,
+		WriterBufferSize: 1 << 20,
+		FlushInterval:    time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close(logger) })
+	return logger, filepath.Join(dir, "default.log")
+}
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestExitHandler(t *testing.T) {
+	logger, path := newBufferedLogger(t)
+	other, _ := newBufferedLogger(t)
+	var code = -1
+	other.ExitFunc = func(c int) { code = c }
+
+	// bufferFlusher可能已经刷新过一次，之后的日志留在缓冲中
+	time.Sleep(50 * time.Millisecond)
+	logger.Info("before exit")
+	other.Fatal("fatal")
+	if code != 1 {
+		t.Errorf("exit code = %d", code)
+	}
+	if !strings.Contains(readLog(t, path), "before exit") {
+		t.Error("the buffered entries of other loggers should be flushed on Fatal")
+	}
+}
+
+func TestRecoverAndLog(t *testing.T) {
+	_, path := newBufferedLogger(t)
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v, want the original panic value", r)
+			}
+		}()
+		defer RecoverAndLog()
+		panic("boom")
+	}()
+	content := readLog(t, path)
+	if !strings.Contains(content, "panic: boom") || !strings.Contains(content, "TestRecoverAndLog") {
+		t.Errorf("panic entry with stack not found:\n%s", content)
+	}
+
+	var code int
+	exitFunc = func(c int) { code = c }
+	defer func() { exitFunc = os.Exit }()
+	func() {
+		defer RecoverAndExit(3)
+		panic("again")
+	}()
+	if code != 3 || !strings.Contains(readLog(t, path), "panic: again") {
+		t.Errorf("code = %d", code)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package mylog
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleSignals(t *testing.T) {
+	logger, path := newBufferedLogger(t)
+	exited := make(chan int, 1)
+	exitFunc = func(c int) { exited <- c }
+	defer func() { exitFunc = os.Exit }()
+	stop := HandleSignals(syscall.SIGUSR2)
+	defer stop()
+
+	time.Sleep(50 * time.Millisecond)
+	logger.Info("before signal")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case code := <-exited:
+		if code != 128+int(syscall.SIGUSR2) {
+			t.Errorf("exit code = %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("signal not handled")
+	}
+	if !strings.Contains(readLog(t, path), "before signal") {
+		t.Error("entries should be flushed before exiting")
+	}
+}
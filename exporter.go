@@ -0,0 +1,504 @@
+package mylog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HTTPFormat is the request body format of an HTTPExporter.
+type HTTPFormat string
+
+const (
+	// HTTPJSONLines posts one JSON object per line (application/x-ndjson).
+	HTTPJSONLines HTTPFormat = "json_lines"
+	// HTTPLoki posts to the Loki push API (/loki/api/v1/push) in JSON, one stream per level.
+	HTTPLoki HTTPFormat = "loki"
+	// HTTPElasticsearch posts to the Elasticsearch bulk API (/_bulk).
+	HTTPElasticsearch HTTPFormat = "elasticsearch"
+)
+
+// HTTPExporterConfig is the configuration of NewHTTPExporter.
+type HTTPExporterConfig struct {
+	// URL the batches are posted to, e.g. http://loki:3100/loki/api/v1/push or http://es:9200/_bulk.
+	URL string
+	// Body format, default is json_lines.
+	Format HTTPFormat
+	// Extra request headers, e.g. Authorization.
+	Headers map[string]string
+	// Compress the request bodies with gzip.
+	Gzip bool
+	// HTTP client, default is a client with a 10 seconds timeout.
+	Client *http.Client
+	// A batch is posted when it has BatchEntries entries (default 1000) or BatchBytes bytes (default 1MB),
+	// or BatchWait (default 1 second) after its first entry. BatchWait is checked by the Flush of Sink,
+	// which is called every 100 milliseconds (or every BatchWait if it is shorter) unless SinkConfig.FlushInterval
+	// is set, a longer FlushInterval delays the batch up to it.
+	BatchEntries int
+	BatchBytes   int
+	BatchWait    time.Duration
+	// Maximum size in bytes of the entries waiting to be posted, default is 16MB.
+	// The oldest batches are dropped when it is exceeded.
+	MaxBufferedBytes int64
+	// Retries of a batch after a network error, 429 or 5xx response, default is 5. Negative disables retries.
+	// The waiting time doubles after every retry from RetryMin (default 100 milliseconds) up to RetryMax (default 30 seconds).
+	// The Retry-After header of 429 and 503 responses is honored, up to RetryMax.
+	MaxRetries int
+	RetryMin   time.Duration
+	RetryMax   time.Duration
+	// Labels of the Loki streams, the level label is added to every stream.
+	Labels map[string]string
+	// Index of the Elasticsearch documents, default is logs.
+	Index string
+}
+
+// HTTPExporterStats are the counters of an HTTPExporter.
+type HTTPExporterStats struct {
+	// Entries accepted by the backend
+	Sent uint64
+	// Entries dropped because the buffer was full, the retries were exhausted or the backend rejected them
+	Dropped uint64
+	// Failed requests, including retried ones
+	Failed uint64
+}
+
+// HTTPExporter batches the entries and posts them to a log aggregation backend in the background,
+// retrying failed requests with exponential backoff. Attach it with LogConfig.Sinks using Sink,
+// Shutdown of the logger then flushes it within the context of Shutdown.
+//
+// The formatter of the entries defaults to JSON for json_lines and elasticsearch,
+// for loki it is the formatter of the log files.
+type HTTPExporter struct {
+	config HTTPExporterConfig
+
+	mu sync.Mutex
+	// 正在收集的批次
+	current *httpBatch
+	// 等待发送的批次
+	ready []*httpBatch
+	// 正在发送的批次
+	inflight *httpBatch
+	// 所有未发送完的日志大小
+	buffered int64
+	// 状态变化时关闭并替换，用于Flush等待
+	changed chan struct{}
+	closed  bool
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	// Shutdown超时后取消正在发送的请求
+	sendCtx    context.Context
+	cancelSend context.CancelFunc
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+type httpBatch struct {
+	items []httpItem
+	size  int64
+	first time.Time
+}
+
+type httpItem struct {
+	time  time.Time
+	level logrus.Level
+	line  []byte
+}
+
+// NewHTTPExporter returns an exporter and starts its background goroutine, which stops on Shutdown.
+func NewHTTPExporter(config HTTPExporterConfig) (*HTTPExporter, error) {
+	if config.URL == "" {
+		return nil, errors.New("http exporter: URL is empty")
+	}
+	if config.Format == "" {
+		config.Format = HTTPJSONLines
+	}
+	switch config.Format {
+	case HTTPJSONLines, HTTPLoki, HTTPElasticsearch:
+	default:
+		return nil, fmt.Errorf("http exporter: unknown format %q", config.Format)
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.BatchEntries <= 0 {
+		config.BatchEntries = 1000
+	}
+	if config.BatchBytes <= 0 {
+		config.BatchBytes = 1 << 20
+	}
+	if config.BatchWait <= 0 {
+		config.BatchWait = time.Second
+	}
+	if config.MaxBufferedBytes <= 0 {
+		config.MaxBufferedBytes = 16 << 20
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 5
+	}
+	if config.RetryMin <= 0 {
+		config.RetryMin = 100 * time.Millisecond
+	}
+	if config.RetryMax <= 0 {
+		config.RetryMax = 30 * time.Second
+	}
+	config.RetryMax = max(config.RetryMax, config.RetryMin)
+	if config.Index == "" {
+		config.Index = "logs"
+	}
+	e := &HTTPExporter{
+		config:  config,
+		changed: make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	e.sendCtx, e.cancelSend = context.WithCancel(context.Background())
+	go e.loop()
+	return e, nil
+}
+
+// Sink returns the Sink to attach the exporter with LogConfig.Sinks. Its Flush posts the batches
+// older than BatchWait without waiting, its Close is like Shutdown with a background context.
+// Its default SinkConfig.FlushInterval is 100 milliseconds, or BatchWait if it is shorter.
+func (e *HTTPExporter) Sink() Sink {
+	return exporterSink{e}
+}
+
+// Stats returns the counters of the exporter.
+func (e *HTTPExporter) Stats() HTTPExporterStats {
+	return HTTPExporterStats{Sent: e.sent.Load(), Dropped: e.dropped.Load(), Failed: e.failed.Load()}
+}
+
+// Flush posts all buffered entries and waits until they are sent or dropped, or ctx is done.
+func (e *HTTPExporter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	e.sealLocked()
+	e.mu.Unlock()
+	for {
+		e.mu.Lock()
+		idle := len(e.ready) == 0 && e.inflight == nil
+		changed := e.changed
+		e.mu.Unlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Shutdown flushes the exporter within ctx and stops it, the entries not sent when ctx is done are dropped.
+// Entries added after Shutdown are dropped. It is safe to call Shutdown more than once.
+func (e *HTTPExporter) Shutdown(ctx context.Context) error {
+	err := e.Flush(ctx)
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		<-e.done
+		return err
+	}
+	e.closed = true
+	e.mu.Unlock()
+	e.cancelSend()
+	close(e.stop)
+	<-e.done
+	return err
+}
+
+func (e *HTTPExporter) add(entry SinkEntry) {
+	line := bytes.TrimRight(entry.Line, "\r\n")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		e.dropped.Add(1)
+		return
+	}
+	if e.current == nil {
+		e.current = &httpBatch{first: time.Now()}
+	}
+	e.current.items = append(e.current.items, httpItem{time: entry.Time, level: entry.Level, line: line})
+	e.current.size += int64(len(line))
+	e.buffered += int64(len(line))
+	if len(e.current.items) >= e.config.BatchEntries || e.current.size >= int64(e.config.BatchBytes) {
+		e.sealLocked()
+	}
+	// 超过内存上限时丢弃最旧的批次
+	for e.buffered > e.config.MaxBufferedBytes && len(e.ready) > 0 {
+		oldest := e.ready[0]
+		e.ready[0] = nil
+		e.ready = e.ready[1:]
+		e.buffered -= oldest.size
+		e.dropped.Add(uint64(len(oldest.items)))
+	}
+}
+
+// 把正在收集的批次加入发送队列
+func (e *HTTPExporter) sealLocked() {
+	if e.current == nil {
+		return
+	}
+	e.ready = append(e.ready, e.current)
+	e.current = nil
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 通知Flush状态已变化
+func (e *HTTPExporter) notifyLocked() {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+func (e *HTTPExporter) loop() {
+	defer close(e.done)
+	// 连续失败时只输出第一个错误
+	failing := false
+	for {
+		e.mu.Lock()
+		var b *httpBatch
+		if len(e.ready) > 0 {
+			b = e.ready[0]
+			e.ready[0] = nil
+			e.ready = e.ready[1:]
+			e.inflight = b
+		}
+		e.mu.Unlock()
+		if b == nil {
+			select {
+			case <-e.wake:
+				continue
+			case <-e.stop:
+				return
+			}
+		}
+
+		err := e.sendWithRetry(b)
+		if err != nil {
+			e.dropped.Add(uint64(len(b.items)))
+			if !failing {
+				fmt.Fprintln(os.Stderr, "mylog: http exporter err:", err)
+			}
+		} else {
+			e.sent.Add(uint64(len(b.items)))
+		}
+		failing = err != nil
+
+		e.mu.Lock()
+		e.inflight = nil
+		e.buffered -= b.size
+		e.notifyLocked()
+		e.mu.Unlock()
+	}
+}
+
+func (e *HTTPExporter) sendWithRetry(b *httpBatch) error {
+	body, err := e.encode(b)
+	if err != nil {
+		return err
+	}
+	wait := e.config.RetryMin
+	for attempt := 0; ; attempt++ {
+		retry, retryAfter, err := e.post(body)
+		if err == nil {
+			return nil
+		}
+		e.failed.Add(1)
+		if !retry || attempt >= e.config.MaxRetries || e.sendCtx.Err() != nil {
+			return err
+		}
+		delay := wait
+		if retryAfter > 0 {
+			delay = min(retryAfter, e.config.RetryMax)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-e.sendCtx.Done():
+			timer.Stop()
+			return err
+		}
+		wait = min(wait*2, e.config.RetryMax)
+	}
+}
+
+// 返回请求是否可以重试，以及服务端要求的等待时间(Retry-After)
+func (e *HTTPExporter) post(body []byte) (retry bool, retryAfter time.Duration, err error) {
+	var contentEncoding string
+	if e.config.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		if err := zw.Close(); err != nil {
+			return false, 0, err
+		}
+		body, contentEncoding = buf.Bytes(), "gzip"
+	}
+	req, err := http.NewRequestWithContext(e.sendCtx, http.MethodPost, e.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, err
+	}
+	if e.config.Format == HTTPLoki {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	for key, value := range e.config.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.config.Client.Do(req)
+	if err != nil {
+		return true, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return retry, retryAfter, fmt.Errorf("POST %s: %s: %s", e.config.URL, resp.Status, bytes.TrimSpace(respBody))
+	}
+	if e.config.Format == HTTPElasticsearch {
+		// _bulk中单个文档失败时状态码仍为200
+		var result struct {
+			Errors bool `json:"errors"`
+		}
+		if json.Unmarshal(respBody, &result) == nil && result.Errors {
+			return false, 0, fmt.Errorf("POST %s: some documents were rejected", e.config.URL)
+		}
+	}
+	return false, 0, nil
+}
+
+// Retry-After可以是秒数或HTTP日期，无效时返回0
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+func (e *HTTPExporter) encode(b *httpBatch) ([]byte, error) {
+	var buf bytes.Buffer
+	switch e.config.Format {
+	case HTTPLoki:
+		type stream struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		}
+		var streams []*stream
+		byLevel := make(map[logrus.Level]*stream)
+		for _, item := range b.items {
+			s := byLevel[item.level]
+			if s == nil {
+				s = &stream{Stream: map[string]string{"level": item.level.String()}}
+				for key, value := range e.config.Labels {
+					s.Stream[key] = value
+				}
+				byLevel[item.level] = s
+				streams = append(streams, s)
+			}
+			s.Values = append(s.Values, [2]string{strconv.FormatInt(item.time.UnixNano(), 10), string(item.line)})
+		}
+		err := json.NewEncoder(&buf).Encode(map[string]any{"streams": streams})
+		return buf.Bytes(), err
+	case HTTPElasticsearch:
+		action, _ := json.Marshal(map[string]any{"index": map[string]string{"_index": e.config.Index}})
+		for _, item := range b.items {
+			buf.Write(action)
+			buf.WriteByte('\n')
+			buf.Write(item.line)
+			buf.WriteByte('\n')
+		}
+	default:
+		for _, item := range b.items {
+			buf.Write(item.line)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// HTTPExporter作为Sink，只在sinkRunner的goroutine中调用
+type exporterSink struct {
+	e *HTTPExporter
+}
+
+func (s exporterSink) Write(entry SinkEntry) error {
+	s.e.add(entry)
+	return nil
+}
+
+// 发送超过BatchWait的批次，不等待
+func (s exporterSink) Flush() error {
+	e := s.e
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.current != nil && time.Since(e.current.first) >= e.config.BatchWait {
+		e.sealLocked()
+	}
+	return nil
+}
+
+func (s exporterSink) Close() error {
+	return s.e.Shutdown(context.Background())
+}
+
+func (s exporterSink) closeContext(ctx context.Context) error {
+	return s.e.Shutdown(ctx)
+}
+
+// 默认每100毫秒检查一次BatchWait，避免批次等到sinkRunner默认1秒的Flush
+func (s exporterSink) defaultFlushInterval() time.Duration {
+	return min(s.e.config.BatchWait, 100*time.Millisecond)
+}
+
+func (s exporterSink) defaultFormatter() logrus.Formatter {
+	switch s.e.config.Format {
+	case HTTPJSONLines:
+		return &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano, CallerPrettyfier: noCaller}
+	case HTTPElasticsearch:
+		return &logrus.JSONFormatter{
+			TimestampFormat:  time.RFC3339Nano,
+			CallerPrettyfier: noCaller,
+			FieldMap:         logrus.FieldMap{logrus.FieldKeyTime: "@timestamp", logrus.FieldKeyMsg: "message"},
+		}
+	}
+	return nil
+}
+
+// 调用者信息已在FILE和FUNC字段中
+func noCaller(*runtime.Frame) (string, string) {
+	return "", ""
+}
@@ -0,0 +1,288 @@
+package mylog
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 记录收到的请求体(已解压)
+type testBackend struct {
+	mu     sync.Mutex
+	bodies []string
+	// 前failures个请求返回503
+	failures atomic.Int32
+	// 返回503时的Retry-After
+	retryAfter string
+}
+
+func (b *testBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if b.failures.Add(-1) >= 0 {
+		if b.retryAfter != "" {
+			w.Header().Set("Retry-After", b.retryAfter)
+		}
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	b.mu.Lock()
+	b.bodies = append(b.bodies, string(data))
+	b.mu.Unlock()
+	w.Write([]byte(`{"errors":false}`))
+}
+
+func (b *testBackend) all() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Join(b.bodies, "")
+}
+
+func newTestExporter(t *testing.T, config HTTPExporterConfig) (*HTTPExporter, *testBackend) {
+	t.Helper()
+	backend := &testBackend{}
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	config.URL = server.URL
+	exporter, err := NewHTTPExporter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exporter, backend
+}
+
+func TestHTTPExporter_JSONLines(t *testing.T) {
+	exporter, backend := newTestExporter(t, HTTPExporterConfig{Gzip: true, BatchEntries: 2})
+	logger, err := NewLogger(LogConfig{LogFileDisable: true, NoConsole: true, Sinks: []SinkConfig{{Sink: exporter.Sink()}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.WithField("user", "tom").Info("first")
+	logger.Warn("second")
+	logger.Error("third")
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(backend.all()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("lines = %q", lines)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["msg"] != "first" || doc["user"] != "tom" || doc["level"] != "info" || doc["FILE"] == nil {
+		t.Errorf("doc = %v", doc)
+	}
+	if stats := exporter.Stats(); stats.Sent != 3 || stats.Dropped != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestHTTPExporter_Loki(t *testing.T) {
+	exporter, backend := newTestExporter(t, HTTPExporterConfig{Format: HTTPLoki, Labels: map[string]string{"app": "api"}})
+	sink := exporter.Sink()
+	now := time.Unix(1700000000, 5)
+	sink.Write(SinkEntry{Time: now, Level: logrus.InfoLevel, Line: []byte("info one\n")})
+	sink.Write(SinkEntry{Time: now, Level: logrus.WarnLevel, Line: []byte("warn one\n")})
+	sink.Write(SinkEntry{Time: now, Level: logrus.InfoLevel, Line: []byte("info two\n")})
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal([]byte(backend.all()), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.Streams) != 2 {
+		t.Fatalf("payload = %+v", payload)
+	}
+	info := payload.Streams[0]
+	if info.Stream["app"] != "api" || info.Stream["level"] != "info" || len(info.Values) != 2 ||
+		info.Values[1] != [2]string{"1700000000000000005", "info two"} {
+		t.Errorf("info stream = %+v", info)
+	}
+	sink.Close()
+}
+
+func TestHTTPExporter_Elasticsearch(t *testing.T) {
+	exporter, backend := newTestExporter(t, HTTPExporterConfig{Format: HTTPElasticsearch, Index: "app-logs"})
+	logger, err := NewLogger(LogConfig{LogFileDisable: true, NoConsole: true, Sinks: []SinkConfig{{Sink: exporter.Sink()}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("indexed")
+	Close(logger)
+
+	lines := strings.Split(strings.TrimSpace(backend.all()), "\n")
+	if len(lines) != 2 || lines[0] != `{"index":{"_index":"app-logs"}}` {
+		t.Fatalf("lines = %q", lines)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["message"] != "indexed" || doc["@timestamp"] == nil {
+		t.Errorf("doc = %v", doc)
+	}
+}
+
+func TestHTTPExporter_Retry(t *testing.T) {
+	exporter, backend := newTestExporter(t, HTTPExporterConfig{RetryMin: time.Millisecond})
+	defer exporter.Shutdown(context.Background())
+	backend.failures.Store(2)
+	exporter.Sink().Write(SinkEntry{Time: time.Now(), Line: []byte(`{"msg":"retried"}`)})
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats := exporter.Stats(); stats.Sent != 1 || stats.Failed != 2 || !strings.Contains(backend.all(), "retried") {
+		t.Errorf("Stats() = %+v, body = %q", stats, backend.all())
+	}
+
+	// 重试次数用完后丢弃
+	backend.failures.Store(100)
+	exporter.Sink().Write(SinkEntry{Time: time.Now(), Line: []byte(`{"msg":"lost"}`)})
+	exporter.Flush(context.Background())
+	if stats := exporter.Stats(); stats.Dropped != 1 || stats.Failed != 2+6 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestHTTPExporter_RetryAfter(t *testing.T) {
+	exporter, backend := newTestExporter(t, HTTPExporterConfig{RetryMin: time.Millisecond, RetryMax: 200 * time.Millisecond})
+	defer exporter.Shutdown(context.Background())
+	// 等待时间不超过RetryMax
+	backend.retryAfter = "3600"
+	backend.failures.Store(1)
+	exporter.Sink().Write(SinkEntry{Time: time.Now(), Line: []byte(`{"msg":"later"}`)})
+	start := time.Now()
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("retried after %v, want RetryMax", elapsed)
+	}
+	if stats := exporter.Stats(); stats.Sent != 1 || stats.Failed != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for value, want := range map[string]time.Duration{
+		"":                              0,
+		"2":                             2 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Tue, 02 Jan 2024 03:04:15 GMT": 10 * time.Second,
+		"Tue, 02 Jan 2024 03:04:00 GMT": 0,
+	} {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestHTTPExporter_BatchWait(t *testing.T) {
+	exporter, backend := newTestExporter(t, HTTPExporterConfig{BatchWait: 50 * time.Millisecond})
+	logger, err := NewLogger(LogConfig{LogFileDisable: true, NoConsole: true, Sinks: []SinkConfig{{Sink: exporter.Sink()}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	// 不完整的批次在BatchWait后发送，不等待sinkRunner默认1秒的Flush
+	logger.Info("partial")
+	for deadline := time.Now().Add(700 * time.Millisecond); !strings.Contains(backend.all(), "partial"); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the partial batch was not sent within BatchWait")
+		}
+	}
+}
+
+func TestHTTPExporter_BoundedMemory(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	exporter, err := NewHTTPExporter(HTTPExporterConfig{URL: server.URL, BatchEntries: 1, MaxBufferedBytes: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []byte(strings.Repeat("x", 30))
+	for i := 0; i < 20; i++ {
+		exporter.Sink().Write(SinkEntry{Time: time.Now(), Line: line})
+	}
+	exporter.mu.Lock()
+	buffered := exporter.buffered
+	exporter.mu.Unlock()
+	if buffered > 100+30 || exporter.Stats().Dropped < 15 {
+		t.Errorf("buffered = %d, Stats() = %+v", buffered, exporter.Stats())
+	}
+
+	// Shutdown在ctx结束后返回，不等待卡住的请求
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := exporter.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() err = %v", err)
+	}
+}
+
+func TestHTTPExporter_LoggerShutdown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer server.Close()
+	exporter, err := NewHTTPExporter(HTTPExporterConfig{URL: server.URL, MaxRetries: 100, RetryMin: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := NewLogger(LogConfig{LogFileDisable: true, NoConsole: true, Sinks: []SinkConfig{{Sink: exporter.Sink()}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("never delivered")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := Shutdown(ctx, logger); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() err = %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("Shutdown should stop retrying when ctx is done")
+	}
+	// 取消的请求在后台丢弃
+	for deadline := time.Now().Add(5 * time.Second); exporter.Stats().Dropped != 1; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats() = %+v", exporter.Stats())
+		}
+	}
+}
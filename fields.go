@@ -0,0 +1,81 @@
+package mylog
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FieldProvider returns the value of a dynamic field, it is called for every entry.
+type FieldProvider func() interface{}
+
+// HostnameProvider returns a FieldProvider of the host name, the name is read once.
+func HostnameProvider() FieldProvider {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	return func() interface{} { return hostname }
+}
+
+// PidProvider returns a FieldProvider of the process id.
+func PidProvider() FieldProvider {
+	pid := os.Getpid()
+	return func() interface{} { return pid }
+}
+
+// GoroutinesProvider returns a FieldProvider of the number of goroutines.
+func GoroutinesProvider() FieldProvider {
+	return func() interface{} { return runtime.NumGoroutine() }
+}
+
+var buildVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	if version == "" || version == "(devel)" {
+		// 没有版本号时使用VCS信息
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	if version == "" {
+		return "unknown"
+	}
+	return version
+})
+
+// BuildInfoProvider returns a FieldProvider of the version of the main module from runtime/debug.ReadBuildInfo,
+// or the VCS revision if the version is unknown (e.g. go run or go build in the module).
+func BuildInfoProvider() FieldProvider {
+	return func() interface{} { return buildVersion() }
+}
+
+// DefaultFieldProviders returns the providers of the hostname and pid fields.
+func DefaultFieldProviders() map[string]FieldProvider {
+	return map[string]FieldProvider{
+		"hostname": HostnameProvider(),
+		"pid":      PidProvider(),
+	}
+}
+
+// 添加固定字段和动态字段，不覆盖entry中已有的字段
+func addFields(entry *logrus.Entry, config *LogConfig) {
+	for key, value := range config.Fields {
+		if _, ok := entry.Data[key]; !ok {
+			entry.Data[key] = value
+		}
+	}
+	for key, provider := range config.FieldProviders {
+		if _, ok := entry.Data[key]; !ok {
+			entry.Data[key] = provider()
+		}
+	}
+}
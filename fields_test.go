@@ -0,0 +1,59 @@
+package mylog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	dir := t.TempDir()
+	fields := map[string]interface{}{"service": "api", "env": "prod"}
+	providers := DefaultFieldProviders()
+	providers["build"] = BuildInfoProvider()
+	logger, err := NewLogger(LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		JSONFormat:          true,
+		DisableWriterBuffer: true,
+		Fields:              fields,
+		FieldProviders:      providers,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 修改传入的map不影响logger
+	fields["service"] = "changed"
+	logger.Info("hello")
+	logger.WithField("env", "dev").Info("override")
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "default.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), content)
+	}
+	var entries []map[string]interface{}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	hostname, _ := os.Hostname()
+	if e := entries[0]; e["service"] != "api" || e["env"] != "prod" || e["hostname"] != hostname ||
+		e["pid"] != float64(os.Getpid()) || e["build"] == "" {
+		t.Errorf("entry = %v", e)
+	}
+	if e := entries[1]; e["env"] != "dev" {
+		t.Errorf("field set by WithField was overwritten: %v", e)
+	}
+}
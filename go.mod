@@ -4,7 +4,6 @@ go 1.24.0
 
 require (
 	github.com/doraemonkeys/doraemon v0.6.8
-	github.com/doraemonkeys/fast-mpmc v1.1.2
 	github.com/sirupsen/logrus v1.9.3
 	go.uber.org/zap v1.27.0
 	golang.org/x/sys v0.37.0
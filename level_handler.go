@@ -0,0 +1,213 @@
+package mylog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelTarget is a logger whose level can be inspected and changed by the handler returned by NewLevelHandler.
+// Use LogrusLevelTarget for loggers created by NewLogger and ZapBuilder.LevelTarget for zap loggers.
+type LevelTarget interface {
+	// Level returns the current level, e.g. info.
+	Level() string
+	// SetLevel changes the level, it returns an error for unknown levels.
+	SetLevel(level string) error
+	// ActiveFiles returns the paths of the log files being written.
+	ActiveFiles() []string
+	// SplitMode describes how the log files are split, e.g. date:daily,size:10485760.
+	SplitMode() string
+}
+
+// LevelStatus is the response body of the handler returned by NewLevelHandler.
+type LevelStatus struct {
+	Level     string   `json:"level"`
+	Files     []string `json:"files"`
+	SplitMode string   `json:"splitMode"`
+	// Time when the level set with a TTL reverts, empty if there is none.
+	RevertAt string `json:"revertAt,omitempty"`
+}
+
+type levelHandler struct {
+	target LevelTarget
+	mu     sync.Mutex
+	// 设置了TTL时恢复的级别和定时器
+	revertLevel string
+	revertAt    time.Time
+	revertTimer *time.Timer
+}
+
+// NewLevelHandler returns an http.Handler that reports the level, active files and split mode
+// of target with GET and changes the level with PUT, like zap.AtomicLevel.ServeHTTP.
+//
+// The PUT body is JSON such as {"level": "debug", "ttl": "10m"}, or a form with the same keys.
+// With a TTL, the level reverts to the level before the change when the TTL expires,
+// a later PUT cancels the pending revert.
+func NewLevelHandler(target LevelTarget) http.Handler {
+	return &levelHandler{target: target}
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if err := h.put(r); err != nil {
+			writeLevelError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeLevelError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.status())
+}
+
+func (h *levelHandler) put(r *http.Request) error {
+	var req struct {
+		Level string `json:"level"`
+		TTL   string `json:"ttl"`
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		req.Level, req.TTL = r.PostFormValue("level"), r.PostFormValue("ttl")
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+	if req.Level == "" {
+		return errors.New("level is required")
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid ttl %q", req.TTL)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	previous := h.target.Level()
+	if h.revertTimer != nil {
+		// 连续设置时恢复到最初的级别
+		h.revertTimer.Stop()
+		h.revertTimer = nil
+		previous = h.revertLevel
+	}
+	if err := h.target.SetLevel(req.Level); err != nil {
+		return err
+	}
+	h.revertLevel, h.revertAt = "", time.Time{}
+	if ttl > 0 {
+		h.revertLevel, h.revertAt = previous, time.Now().Add(ttl)
+		var timer *time.Timer
+		timer = time.AfterFunc(ttl, func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if h.revertTimer != timer {
+				return
+			}
+			_ = h.target.SetLevel(h.revertLevel)
+			h.revertTimer, h.revertLevel, h.revertAt = nil, "", time.Time{}
+		})
+		h.revertTimer = timer
+	}
+	return nil
+}
+
+func (h *levelHandler) status() LevelStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := LevelStatus{
+		Level:     h.target.Level(),
+		Files:     h.target.ActiveFiles(),
+		SplitMode: h.target.SplitMode(),
+	}
+	if status.Files == nil {
+		status.Files = []string{}
+	}
+	if !h.revertAt.IsZero() {
+		status.RevertAt = h.revertAt.Format(time.RFC3339)
+	}
+	return status
+}
+
+func writeLevelError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// LogrusLevelTarget returns the LevelTarget of a logger created by NewLogger or InitGlobalLogger.
+func LogrusLevelTarget(logger *logrus.Logger) LevelTarget {
+	return logrusLevelTarget{logger: logger}
+}
+
+type logrusLevelTarget struct {
+	logger *logrus.Logger
+}
+
+func (t logrusLevelTarget) Level() string {
+	if hooks := findLogHooks(t.logger); len(hooks) > 0 {
+		return hooks[0].level(t.logger).String()
+	}
+	return t.logger.GetLevel().String()
+}
+
+func (t logrusLevelTarget) SetLevel(level string) error {
+	l, err := parseLevelStrict(level)
+	if err != nil {
+		return err
+	}
+	if hooks := findLogHooks(t.logger); len(hooks) > 0 {
+		hooks[0].setLevel(t.logger, l)
+		return nil
+	}
+	t.logger.SetLevel(l)
+	return nil
+}
+
+func (t logrusLevelTarget) ActiveFiles() []string {
+	var files []string
+	for _, hook := range findLogHooks(t.logger) {
+		for path := range hook.activeLogFiles() {
+			files = append(files, path)
+		}
+	}
+	slices.Sort(files)
+	return files
+}
+
+func (t logrusLevelTarget) SplitMode() string {
+	if hooks := findLogHooks(t.logger); len(hooks) > 0 {
+		return hooks[0].splitMode()
+	}
+	return "none"
+}
+
+// 如 none、date:daily、size:10485760、date:hourly,size:10485760
+func (hook *logHook) splitMode() string {
+	hook.WriterLock.RLock()
+	defer hook.WriterLock.RUnlock()
+	var mode string
+	if hook.LogConfig.DateSplit {
+		mode = "date:" + string(hook.period)
+	}
+	if hook.LogConfig.MaxLogSize > 0 {
+		if mode != "" {
+			mode += ","
+		}
+		mode += fmt.Sprintf("size:%d", hook.LogConfig.MaxLogSize)
+	}
+	if mode == "" {
+		mode = "none"
+	}
+	return mode
+}
@@ -0,0 +1,74 @@
+package mylog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLevelHandler(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:     dir,
+		NoConsole:  true,
+		DateSplit:  true,
+		MaxLogSize: 1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	handler := NewLevelHandler(LogrusLevelTarget(logger))
+
+	do := func(method, body string) (int, LevelStatus) {
+		req := httptest.NewRequest(method, "/log/level", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var status LevelStatus
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, status
+	}
+
+	code, status := do(http.MethodGet, "")
+	if code != http.StatusOK || status.Level != "info" || status.SplitMode != "date:daily,size:1024" {
+		t.Fatalf("GET = %d %+v", code, status)
+	}
+	if len(status.Files) != 1 || filepath.Dir(status.Files[0]) != dir {
+		t.Errorf("files = %v", status.Files)
+	}
+
+	if code, _ := do(http.MethodPut, `{"level": "verbose"}`); code != http.StatusBadRequest {
+		t.Errorf("PUT unknown level = %d, want 400", code)
+	}
+	if code, _ := do(http.MethodPost, `{"level": "debug"}`); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", code)
+	}
+
+	code, status = do(http.MethodPut, `{"level": "debug"}`)
+	if code != http.StatusOK || status.Level != "debug" || status.RevertAt != "" {
+		t.Fatalf("PUT = %d %+v", code, status)
+	}
+	// 连续设置TTL时恢复到设置TTL前的级别
+	do(http.MethodPut, `{"level": "trace", "ttl": "50ms"}`)
+	code, status = do(http.MethodPut, `{"level": "warn", "ttl": "50ms"}`)
+	if code != http.StatusOK || status.Level != "warning" || status.RevertAt == "" {
+		t.Fatalf("PUT with ttl = %d %+v", code, status)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for logger.GetLevel() != logrus.DebugLevel {
+		if time.Now().After(deadline) {
+			t.Fatalf("level = %v, want debug after ttl", logger.GetLevel())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
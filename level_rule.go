@@ -0,0 +1,193 @@
+package mylog
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelRule sets the minimum level of the entries logged from the callers matching Prefix.
+type LevelRule struct {
+	// Package path prefix (e.g. github.com/foo/bar/db, sub packages included) or
+	// file path prefix (e.g. /src/app/internal/db/) of the caller.
+	Prefix string
+	// Minimum level (panic, fatal, error, warn, info, debug, trace)
+	Level string
+}
+
+// UnmarshalText parses a rule in the form prefix=level, it is used by ConfigFromEnv.
+func (r *LevelRule) UnmarshalText(text []byte) error {
+	prefix, level, ok := strings.Cut(string(text), "=")
+	if !ok {
+		return fmt.Errorf("invalid level rule %q, want prefix=level", text)
+	}
+	r.Prefix, r.Level = strings.TrimSpace(prefix), strings.TrimSpace(level)
+	return nil
+}
+
+func validateLevelRules(rules []LevelRule) error {
+	var errs []error
+	for _, rule := range rules {
+		if rule.Prefix == "" {
+			errs = append(errs, errors.New("the prefix of a level rule must not be empty"))
+		}
+		if _, err := parseLevelStrict(rule.Level); err != nil {
+			errs = append(errs, fmt.Errorf("level rule %q: %w", rule.Prefix, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type levelRule struct {
+	prefix string
+	level  logrus.Level
+}
+
+// 按调用位置的级别规则，匹配结果按调用位置(PC)缓存
+type levelRules struct {
+	// 按前缀长度从长到短排序
+	rules []levelRule
+	// 最低的级别(数值最大)
+	maxLevel logrus.Level
+	// uintptr -> int，匹配的规则下标，-1表示没有匹配的规则
+	cache sync.Map
+}
+
+func newLevelRules(rules []LevelRule) *levelRules {
+	if len(rules) == 0 {
+		return nil
+	}
+	r := &levelRules{}
+	for _, rule := range rules {
+		level := PraseLevel(rule.Level)
+		r.rules = append(r.rules, levelRule{prefix: rule.Prefix, level: level})
+		if level > r.maxLevel {
+			r.maxLevel = level
+		}
+	}
+	sort.SliceStable(r.rules, func(i, j int) bool {
+		return len(r.rules[i].prefix) > len(r.rules[j].prefix)
+	})
+	return r
+}
+
+// 返回调用位置匹配的规则的级别
+func (r *levelRules) lookup(caller *runtime.Frame) (logrus.Level, bool) {
+	if v, ok := r.cache.Load(caller.PC); ok {
+		idx := v.(int)
+		if idx < 0 {
+			return 0, false
+		}
+		return r.rules[idx].level, true
+	}
+	idx := r.match(caller)
+	r.cache.Store(caller.PC, idx)
+	if idx < 0 {
+		return 0, false
+	}
+	return r.rules[idx].level, true
+}
+
+func (r *levelRules) match(caller *runtime.Frame) int {
+	pkg := callerPackage(caller.Function)
+	for i, rule := range r.rules {
+		if pkg == rule.prefix || strings.HasPrefix(pkg, strings.TrimSuffix(rule.prefix, "/")+"/") {
+			return i
+		}
+		if strings.HasPrefix(caller.File, rule.prefix) {
+			return i
+		}
+	}
+	return -1
+}
+
+// github.com/foo/bar.(*T).Method -> github.com/foo/bar
+func callerPackage(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// 有级别规则时logger的级别为所有级别中最低的，由hook按调用位置过滤
+func (hook *logHook) levelAllowed(entry *logrus.Entry) bool {
+	rules := hook.levelRules.Load()
+	if rules == nil {
+		return true
+	}
+	base := logrus.Level(hook.baseLevel.Load())
+	if entry.Caller == nil {
+		return entry.Level <= base
+	}
+	if level, ok := rules.lookup(entry.Caller); ok {
+		return entry.Level <= level
+	}
+	return entry.Level <= base
+}
+
+// 设置没有匹配的规则时的级别，并更新logger的级别
+func (hook *logHook) setLevel(logger *logrus.Logger, base logrus.Level) {
+	hook.baseLevel.Store(uint32(base))
+	level := base
+	if rules := hook.levelRules.Load(); rules != nil && rules.maxLevel > level {
+		level = rules.maxLevel
+	}
+	logger.SetLevel(level)
+}
+
+// 返回没有匹配的规则时的级别
+func (hook *logHook) level(logger *logrus.Logger) logrus.Level {
+	if hook.levelRules.Load() == nil {
+		return logger.GetLevel()
+	}
+	return logrus.Level(hook.baseLevel.Load())
+}
+
+// 是否需要在格式化之前过滤控制台输出
+func (hook *logHook) filtered() bool {
+	return hook.levelRules.Load() != nil || hook.sampler.Load() != nil || hook.consoleDedup.Load() != nil
+}
+
+// 有级别规则或采样时包装formatter，在格式化之前过滤控制台输出
+func (hook *logHook) setFormatter(logger *logrus.Logger, formatter logrus.Formatter) {
+	if hook.filtered() {
+		formatter = &filterFormatter{Formatter: formatter, hook: hook}
+	}
+	logger.SetFormatter(formatter)
+}
+
+type filterFormatter struct {
+	logrus.Formatter
+	hook *logHook
+}
+
+func (f *filterFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if isDropped(entry) || !f.hook.levelAllowed(entry) {
+		return nil, nil
+	}
+	d := f.hook.consoleDedup.Load()
+	if d == nil || isConsoleOnly(entry) {
+		return f.Formatter.Format(entry)
+	}
+	suppress, level, repeated := d.check(entry, time.Now())
+	if suppress {
+		return nil, nil
+	}
+	line, err := f.Formatter.Format(entry)
+	if err != nil || repeated == 0 {
+		return line, err
+	}
+	// 先输出上一条日志的重复次数
+	prev, err := f.Formatter.Format(f.hook.repeatedEntry(level, repeated))
+	if err != nil {
+		return line, nil
+	}
+	return append(prev, line...), nil
+}
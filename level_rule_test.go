@@ -0,0 +1,147 @@
+package mylog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func Test_callerPackage(t *testing.T) {
+	tests := []struct {
+		function string
+		want     string
+	}{
+		{"main.main", "main"},
+		{"github.com/doraemonkeys/mylog.(*logHook).Fire", "github.com/doraemonkeys/mylog"},
+		{"github.com/foo/bar.v2/db.Query.func1", "github.com/foo/bar.v2/db"},
+		{"runtime.goexit", "runtime"},
+	}
+	for _, tt := range tests {
+		if got := callerPackage(tt.function); got != tt.want {
+			t.Errorf("callerPackage(%q) = %q, want %q", tt.function, got, tt.want)
+		}
+	}
+}
+
+func TestLevelRules(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	tests := []struct {
+		name      string
+		logLevel  string
+		rules     []LevelRule
+		wantLevel logrus.Level
+		want      []string
+		notWant   []string
+	}{
+		{
+			name:      "package rule lowers the level",
+			logLevel:  WarnLevel,
+			rules:     []LevelRule{{Prefix: "github.com/doraemonkeys/mylog", Level: DebugLevel}},
+			wantLevel: logrus.DebugLevel,
+			want:      []string{"debug-msg", "info-msg", "warn-msg"},
+			notWant:   []string{"trace-msg"},
+		},
+		{
+			name:      "file rule raises the level",
+			logLevel:  DebugLevel,
+			rules:     []LevelRule{{Prefix: filepath.Dir(file), Level: ErrorLevel}},
+			wantLevel: logrus.DebugLevel,
+			want:      []string{"error-msg"},
+			notWant:   []string{"debug-msg", "info-msg", "warn-msg"},
+		},
+		{
+			name:     "longest prefix wins",
+			logLevel: InfoLevel,
+			rules: []LevelRule{
+				{Prefix: "github.com/doraemonkeys", Level: TraceLevel},
+				{Prefix: "github.com/doraemonkeys/mylog", Level: WarnLevel},
+			},
+			wantLevel: logrus.TraceLevel,
+			want:      []string{"warn-msg", "error-msg"},
+			notWant:   []string{"trace-msg", "debug-msg", "info-msg"},
+		},
+		{
+			name:      "no matching rule uses LogLevel",
+			logLevel:  WarnLevel,
+			rules:     []LevelRule{{Prefix: "example.com/other", Level: TraceLevel}},
+			wantLevel: logrus.TraceLevel,
+			want:      []string{"warn-msg", "error-msg"},
+			notWant:   []string{"trace-msg", "debug-msg", "info-msg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			logger, err := NewLogger(LogConfig{
+				LogDir:              dir,
+				LogLevel:            tt.logLevel,
+				LevelRules:          tt.rules,
+				DisableWriterBuffer: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var console bytes.Buffer
+			logger.SetOutput(&console)
+			if logger.GetLevel() != tt.wantLevel {
+				t.Errorf("logger level = %v, want %v", logger.GetLevel(), tt.wantLevel)
+			}
+			for i := 0; i < 2; i++ {
+				logger.Trace("trace-msg")
+				logger.Debug("debug-msg")
+				logger.Info("info-msg")
+				logger.Warn("warn-msg")
+				logger.Error("error-msg")
+			}
+			if err := Close(logger); err != nil {
+				t.Fatal(err)
+			}
+			content, err := os.ReadFile(filepath.Join(dir, "default.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, output := range map[string]string{"file": string(content), "console": console.String()} {
+				for _, msg := range tt.want {
+					if strings.Count(output, msg) != 2 {
+						t.Errorf("%s output has %d %s, want 2", name, strings.Count(output, msg), msg)
+					}
+				}
+				for _, msg := range tt.notWant {
+					if strings.Contains(output, msg) {
+						t.Errorf("%s output should not contain %s", name, msg)
+					}
+				}
+			}
+		})
+	}
+}
+
+// 规则比LogLevel更详细时，其他调用位置的Debug日志需要创建后再丢弃
+func BenchmarkLevelRules(b *testing.B) {
+	for _, bm := range []struct {
+		name  string
+		rules []LevelRule
+	}{
+		{"no rules", nil},
+		{"less verbose rule", []LevelRule{{Prefix: "example.com/other", Level: ErrorLevel}}},
+		{"more verbose rule", []LevelRule{{Prefix: "example.com/other", Level: DebugLevel}}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			logger, err := NewLogger(LogConfig{LogDir: b.TempDir(), NoConsole: true, LevelRules: bm.rules})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer Close(logger)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				logger.Debug("dropped")
+			}
+		})
+	}
+}
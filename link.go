@@ -0,0 +1,69 @@
+package mylog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// current.log -> current_error.log
+func errorLinkName(name string) string {
+	return routeLinkName(name, "error")
+}
+
+// current.log -> current_warn.log
+func routeLinkName(name, route string) string {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "_" + route + ext
+}
+
+// 更新LogDir中指向当前日志文件的符号链接，失败时只输出到标准错误
+func (hook *logHook) updateCurrentLinks() {
+	name := hook.LogConfig.CurrentLink
+	if name == "" {
+		return
+	}
+	dir := hook.LogConfig.LogDir
+	if dir == "" {
+		dir = "."
+	}
+	if hook.OtherWriter != nil {
+		if err := replaceSymlink(dir, name, hook.OtherWriter.Name()); err != nil {
+			fmt.Fprintln(os.Stderr, "mylog: update current link err:", err)
+		}
+	}
+	if hook.ErrWriter != nil {
+		// 错误日志文件可能还未创建
+		if err := replaceSymlink(dir, errorLinkName(name), hook.ErrWriter.Path()); err != nil {
+			fmt.Fprintln(os.Stderr, "mylog: update current link err:", err)
+		}
+	}
+	for i, w := range hook.RouteWriters {
+		if err := replaceSymlink(dir, routeLinkName(name, hook.routes[i].name), w.Path()); err != nil {
+			fmt.Fprintln(os.Stderr, "mylog: update current link err:", err)
+		}
+	}
+}
+
+// 先创建临时链接再重命名，读取链接的程序不会看到链接不存在
+func replaceSymlink(dir, name, target string) error {
+	// 使用相对路径，移动日志目录后链接仍然有效
+	if rel, err := filepath.Rel(dir, target); err == nil {
+		target = rel
+	}
+	link := filepath.Join(dir, name)
+	if current, err := os.Readlink(link); err == nil && current == target {
+		return nil
+	}
+	tmp := filepath.Join(dir, "."+name+".tmp")
+	_ = os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package mylog
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_errorLinkName(t *testing.T) {
+	for name, want := range map[string]string{
+		"current.log": "current_error.log",
+		"current":     "current_error",
+		"app.cur.txt": "app.cur_error.txt",
+	} {
+		if got := errorLinkName(name); got != want {
+			t.Errorf("errorLinkName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCurrentLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks may need privileges")
+	}
+	dir := t.TempDir()
+	config := LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		DisableWriterBuffer: true,
+		ErrSeparate:         true,
+		DateSplit:           true,
+		MaxLogSize:          1 << 20,
+		CurrentLink:         "current.log",
+	}
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().Format("2006_01_02")
+	checkLink := func(name, want string) {
+		t.Helper()
+		target, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target != want {
+			t.Errorf("%s -> %s, want %s", name, target, want)
+		}
+	}
+	checkLink("current.log", filepath.Join(today, today+".log"))
+	checkLink("current_error.log", filepath.Join(today, today+"_error.log"))
+
+	if err := Rotate(logger); err != nil {
+		t.Fatal(err)
+	}
+	logger.Error("via link")
+	checkLink("current.log", filepath.Join(today, today+".1.log"))
+	checkLink("current_error.log", filepath.Join(today, today+"_error.1.log"))
+	if content := readLog(t, filepath.Join(dir, "current_error.log")); !strings.Contains(content, "via link") {
+		t.Errorf("current_error.log = %q", content)
+	}
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启时续写已有的分段
+	if err := os.Remove(filepath.Join(dir, "current.log")); err != nil {
+		t.Fatal(err)
+	}
+	logger, err = NewLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	checkLink("current.log", filepath.Join(today, today+".1.log"))
+	if _, err := os.Stat(filepath.Join(dir, ".current.log.tmp")); !os.IsNotExist(err) {
+		t.Errorf("temporary link left behind, err = %v", err)
+	}
+}
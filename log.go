@@ -2,11 +2,13 @@ package mylog
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,11 +17,30 @@ import (
 )
 
 func (hook *logHook) Fire(entry *logrus.Entry) error {
-	if hook.LogConfig.key != "" {
-		entry.Data[hook.LogConfig.key] = hook.LogConfig.value
+	if isConsoleOnly(entry) {
+		return nil
+	}
+	if !hook.levelAllowed(entry) {
+		return nil
 	}
+	if s := hook.sampler.Load(); s != nil && !s.allow(entry, time.Now()) {
+		// 控制台输出时同样丢弃
+		markDropped(entry)
+		return nil
+	}
+
+	// 配置可能被UpdateConfig修改，使用快照
+	hook.WriterLock.RLock()
+	config := hook.LogConfig
+	hook.WriterLock.RUnlock()
 
-	if !hook.LogConfig.DisableCaller && entry.Caller != nil {
+	if config.key != "" {
+		entry.Data[config.key] = config.value
+	}
+	addContextFields(entry)
+	addFields(entry, &config)
+
+	if !config.DisableCaller && entry.Caller != nil {
 		file := entry.Caller.File
 		file = getShortFileName(file, fmt.Sprint(entry.Caller.Line))
 		funcName := entry.Caller.Function
@@ -29,23 +50,35 @@ func (hook *logHook) Fire(entry *logrus.Entry) error {
 		entry.Data["FILE"] = file
 		entry.Data["FUNC"] = funcName
 
-		if !hook.LogConfig.ShowShortFileInConsole {
+		if !config.ShowShortFileInConsole {
 			defer delete(entry.Data, "FILE")
 		}
-		if !hook.LogConfig.ShowFuncInConsole {
+		if !config.ShowFuncInConsole {
 			defer delete(entry.Data, "FUNC")
 		}
 	}
 
+	hook.fireSinks(entry)
+
 	//取消日志输出到文件
-	if hook.LogConfig.LogFileDisable {
+	if config.LogFileDisable {
 		return nil
 	}
 
 	//msg前添加固定前缀 DORAEMON
 	//entry.Message = "DORAEMON " + entry.Message
 
-	line, err := entry.Bytes()
+	if d := hook.fileDedup.Load(); d != nil {
+		suppress, level, repeated := d.check(entry, time.Now())
+		if suppress {
+			return nil
+		}
+		if repeated > 0 {
+			hook.writeRepeated(level, repeated)
+		}
+	}
+
+	line, err := hook.format(entry)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Unable to read entry, %v", err)
 		return err
@@ -53,59 +86,116 @@ func (hook *logHook) Fire(entry *logrus.Entry) error {
 	line = eliminateColor(line)
 
 	hook.checkSplit()
+	return hook.file.Write(SinkEntry{Time: entry.Time, Level: entry.Level, Line: line})
+}
+
+// 使用logger的formatter格式化entry(跳过控制台的过滤)
+func (hook *logHook) format(entry *logrus.Entry) ([]byte, error) {
+	if f, ok := entry.Logger.Formatter.(*filterFormatter); ok {
+		return f.Formatter.Format(entry)
+	}
+	return entry.Bytes()
+}
 
-	// ------------------- 加锁写入文件/缓冲 -------------------
+// 写入已格式化的日志，开启缓冲时加入队列由bufferFlusher写入
+func (hook *logHook) writeLine(level logrus.Level, line []byte) error {
+	// ------------------- 加锁写入文件/队列 -------------------
 	hook.WriterLock.RLock()
-	defer func() {
+	if hook.closed || hook.LogConfig.LogFileDisable {
+		// 已关闭或者已通过UpdateConfig取消输出到文件
 		hook.WriterLock.RUnlock()
-		if !hook.LogConfig.DisableWriterBuffer &&
-			(entry.Level == logrus.PanicLevel || entry.Level == logrus.FatalLevel) {
+		return nil
+	}
+	hook.LogSize.Add(hook.lineSize(level, line))
+	if !hook.LogConfig.DisableWriterBuffer {
+		syncOnError := hook.LogConfig.SyncOnError
+		hook.WriterLock.RUnlock()
+		// 不持有锁入队，队列满时可能阻塞直到bufferFlusher写入
+		pushed := hook.bufferQueue.push(line, level)
+		// 即将退出，无论是否入队都写入队列中的日志
+		if level == logrus.PanicLevel || level == logrus.FatalLevel {
 			hook.WriterLock.Lock()
-			_ = hook.OtherBufWriter.Flush()
+			if !hook.closed {
+				hook.drainQueue()
+				if hook.OtherBufWriter != nil {
+					_ = hook.OtherBufWriter.Flush()
+				}
+			}
 			hook.WriterLock.Unlock()
 		}
-	}()
-
-	if hook.ErrWriter != nil && entry.Level <= logrus.ErrorLevel {
-		// 单独输出的错误日志也算在日志大小限制内
-		hook.LogSize += int64(len(line))
-		_, err := hook.ErrWriter.Write(line)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Unable to write error to the file, %v", err)
-			return err
-		}
-		if hook.LogConfig.ErrNotInNormal {
+		if !pushed {
 			return nil
 		}
+		if level <= logrus.ErrorLevel && syncOnError {
+			return hook.sync()
+		}
+		return nil
 	}
+	defer hook.WriterLock.RUnlock()
 
 	// safe check
-	if hook.OtherWriter == nil && hook.OtherBufWriter == nil {
-		fmt.Fprintf(os.Stderr, "Unexpected error, OtherWriter and OtherBufWriter are both nil")
-		return errors.New("unexpected error, OtherWriter and OtherBufWriter are both nil")
-	}
-	if hook.LogConfig.DisableWriterBuffer && hook.OtherWriter == nil {
+	if hook.OtherWriter == nil {
 		fmt.Fprintf(os.Stderr, "Unexpected error, OtherWriter is nil when DisableWriterBuffer is true")
 		return errors.New("unexpected error, OtherWriter is nil when DisableWriterBuffer is true")
 	}
-	if !hook.LogConfig.DisableWriterBuffer && hook.OtherBufWriter == nil {
-		fmt.Fprintf(os.Stderr, "Unexpected error, OtherBufWriter is nil when DisableWriterBuffer is false")
-		return errors.New("unexpected error, OtherBufWriter is nil when DisableWriterBuffer is false")
+	err := hook.writeTo(hook.OtherWriter, level, line)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write log to the file, %v", err)
+		return err
+	}
+	if level <= logrus.ErrorLevel && hook.LogConfig.SyncOnError {
+		return hook.fsync()
 	}
+	hook.syncIfNeeded()
+	return nil
+}
 
-	if hook.LogConfig.DisableWriterBuffer {
-		_, err := hook.OtherWriter.Write(line)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Unable to write log to the file, %v", err)
-			return err
+// 写入后增加的日志大小，单独输出的错误日志和路由的文件也算在日志大小限制内
+func (hook *logHook) lineSize(level logrus.Level, line []byte) int64 {
+	copies, inNormal := 0, true
+	if hook.ErrWriter != nil && level <= logrus.ErrorLevel {
+		copies++
+		inNormal = !hook.LogConfig.ErrNotInNormal
+	}
+	for i := range hook.RouteWriters {
+		if hook.routes[i].match(level) {
+			copies++
+			inNormal = inNormal && !hook.routes[i].exclusive
 		}
-	} else {
-		hook.bufferQueue.Push(line)
 	}
+	if inNormal {
+		copies++
+	}
+	return int64(copies) * int64(len(line))
+}
 
-	hook.LogSize += int64(len(line))
-
-	return nil
+// 错误级别及以上的日志在分离错误日志时写入ErrWriter，匹配路由的日志写入路由的文件，
+// 其余写入w，必须持有WriterLock(读锁或写锁)调用
+func (hook *logHook) writeTo(w io.Writer, level logrus.Level, line []byte) error {
+	inNormal := true
+	if hook.ErrWriter != nil && level <= logrus.ErrorLevel {
+		if _, err := hook.ErrWriter.Write(line); err != nil {
+			return err
+		}
+		hook.unsynced.Add(int64(len(line)))
+		inNormal = !hook.LogConfig.ErrNotInNormal
+	}
+	for i, rw := range hook.RouteWriters {
+		if !hook.routes[i].match(level) {
+			continue
+		}
+		if _, err := rw.Write(line); err != nil {
+			return err
+		}
+		hook.unsynced.Add(int64(len(line)))
+		inNormal = inNormal && !hook.routes[i].exclusive
+	}
+	if w == nil || !inNormal {
+		return nil
+	}
+	_, err := w.Write(line)
+	hook.unsynced.Add(int64(len(line)))
+	return err
 }
 
 func (hook *logHook) Levels() []logrus.Level {
@@ -117,56 +207,52 @@ func (hook *logHook) Levels() []logrus.Level {
 
 // 检查是否需要分割日志
 func (hook *logHook) checkSplit() {
+	hook.WriterLock.RLock()
+	need := hook.needSplit(time.Now())
+	hook.WriterLock.RUnlock()
+	if !need {
+		return
+	}
+
+	hook.WriterLock.Lock()
+	defer hook.WriterLock.Unlock()
 	if hook.LogConfig.DateSplit {
 		//按日期分割
-		now := time.Now().In(hook.LogConfig.TimeLocation).Format(hook.dateFmt)
+		now := hook.periodKey(time.Now())
 		if hook.FileDate != now {
-			hook.WriterLock.Lock()
-			if hook.FileDate == now {
-				//已经分割过了
-				hook.WriterLock.Unlock()
-				return
-			}
 			hook.FileDate = now
+			hook.FileSeq = 0
 			hook.split()
-			hook.WriterLock.Unlock()
+			return
 		}
-		return
 	}
-
-	if hook.LogConfig.MaxLogSize > 0 {
+	if hook.LogConfig.MaxLogSize > 0 && hook.LogSize.Load() >= hook.LogConfig.MaxLogSize {
 		//按大小分割
-		if hook.LogSize >= hook.LogConfig.MaxLogSize {
-			//fmt.Println("日志大小超过限制，开始分割日志", hook.LogSize, hook.LogConfig.MaxLogSize)
-			hook.WriterLock.Lock()
-			if hook.LogSize < hook.LogConfig.MaxLogSize {
-				//已经分割过了
-				hook.WriterLock.Unlock()
-				return
-			}
-			hook.LogSize = 0
-			hook.split()
-			hook.WriterLock.Unlock()
-		}
-		return
+		//fmt.Println("日志大小超过限制，开始分割日志", hook.LogSize, hook.LogConfig.MaxLogSize)
+		hook.LogSize.Store(0)
+		hook.FileSeq++
+		hook.split()
 	}
+	//已经分割过了
+}
+
+// 必须持有WriterLock(读锁或写锁)调用
+func (hook *logHook) needSplit(now time.Time) bool {
+	if hook.LogConfig.DateSplit && hook.FileDate != hook.periodKey(now) {
+		return true
+	}
+	return hook.LogConfig.MaxLogSize > 0 && hook.LogSize.Load() >= hook.LogConfig.MaxLogSize
 }
 
 // 必须加锁调用
 func (hook *logHook) split() {
-	oldErrWriter := hook.ErrWriter
-	oldOtherWriter := hook.OtherWriter
-	oldOtherBufWriter := hook.OtherBufWriter
-	if oldOtherBufWriter != nil {
-		oldOtherBufWriter.Flush()
+	if hook.closed {
+		return
 	}
-	err := hook.updateNewLogPathAndFile()
+	closedFiles, err := hook.switchFiles()
 	if err != nil {
 		msg := fmt.Sprintf("ERROR!!!!!!!!!!!!!!!!!!!!!!!! split log file err:%v !!!!!!!!!!!!!!!!!!!!!!!!ERROR\n", err)
 		fmt.Fprint(os.Stderr, msg)
-		hook.ErrWriter = oldErrWriter
-		hook.OtherWriter = oldOtherWriter
-		hook.OtherBufWriter = oldOtherBufWriter
 		if hook.ErrWriter != nil {
 			hook.ErrWriter.Write([]byte(msg))
 		} else if hook.OtherWriter != nil {
@@ -176,12 +262,85 @@ func (hook *logHook) split() {
 		}
 		return
 	}
-	if oldErrWriter != nil {
-		oldErrWriter.Close()
+	hook.afterSplit(closedFiles)
+}
+
+// 必须加锁调用，关闭当前的文件并通过updateNewLogPathAndFile打开新文件，失败时恢复原来的文件。
+// 返回已关闭的文件。
+func (hook *logHook) switchFiles() ([]string, error) {
+	oldErrWriter := hook.ErrWriter
+	oldRouteWriters := hook.RouteWriters
+	oldOtherWriter := hook.OtherWriter
+	oldOtherBufWriter := hook.OtherBufWriter
+	// 队列中的日志属于旧文件
+	hook.drainQueue()
+	if oldOtherBufWriter != nil {
+		oldOtherBufWriter.Flush()
+	}
+	err := hook.updateNewLogPathAndFile()
+	if err != nil {
+		hook.ErrWriter = oldErrWriter
+		hook.RouteWriters = oldRouteWriters
+		hook.OtherWriter = oldOtherWriter
+		hook.OtherBufWriter = oldOtherBufWriter
+		return nil, err
 	}
+	var closedFiles []string
+	closedFiles = closeLazyWriters(closedFiles, oldErrWriter)
+	closedFiles = closeLazyWriters(closedFiles, oldRouteWriters...)
 	if oldOtherWriter != nil {
+		closedFiles = append(closedFiles, oldOtherWriter.Name())
 		oldOtherWriter.Close()
 	}
+	return closedFiles, nil
+}
+
+// 在后台压缩已关闭的日志文件，并按保留策略清理日志
+func (hook *logHook) afterSplit(closedFiles []string) {
+	var compressFiles []string
+	if hook.LogConfig.Compress {
+		compressFiles = hook.compressibleFiles(closedFiles)
+	}
+	excess := hook.LogConfig.MaxKeepDays > 0 || hook.LogConfig.MaxTotalSize > 0 || hook.LogConfig.MaxBackups > 0
+	if len(compressFiles) == 0 && !excess {
+		return
+	}
+	hook.splitWg.Add(1)
+	go func() {
+		defer hook.splitWg.Done()
+		hook.splitMu.Lock()
+		for _, path := range compressFiles {
+			if err := compressFile(path); err != nil {
+				fmt.Fprintf(os.Stderr, "compress log file %s err:%v\n", path, err)
+			}
+		}
+		hook.splitMu.Unlock()
+		if excess {
+			hook.applyRetention()
+		}
+	}()
+}
+
+// 过滤掉需要保留的文件和仍在写入的文件(分割后可能重新打开了同一个文件)
+func (hook *logHook) compressibleFiles(paths []string) []string {
+	var active []string
+	if hook.OtherWriter != nil {
+		active = append(active, hook.OtherWriter.Name())
+	}
+	for _, w := range hook.lazyWriters() {
+		active = append(active, w.Path())
+	}
+	var ret []string
+	for _, path := range paths {
+		if hook.hasKeepSuffix(filepath.Base(path)) {
+			continue
+		}
+		if slices.ContainsFunc(active, func(a string) bool { return filepath.Clean(a) == filepath.Clean(path) }) {
+			continue
+		}
+		ret = append(ret, path)
+	}
+	return ret
 }
 
 func (hook *logHook) updateNewLogPathAndFile() error {
@@ -200,41 +359,133 @@ func (hook *logHook) updateNewLogPathAndFile() error {
 	}
 
 	//更新日期(不多余，split_size也会用到)
-	hook.FileDate = time.Now().In(hook.LogConfig.TimeLocation).Format(hook.dateFmt)
+	now := time.Now().In(hook.LogConfig.TimeLocation)
+	hook.FileDate = hook.periodKey(now)
+	//按大小分割时，文件名中为创建时间；按日期分割时，为周期的开始时间
+	hook.fileTime = now
+	if hook.LogConfig.DateSplit {
+		hook.fileTime = hook.periodStartOf(now)
+	}
 
-	var tempFileName string
-	//默认情况
-	if !hook.LogConfig.DateSplit && hook.LogConfig.MaxLogSize == 0 {
-		tempFileName = hook.LogConfig.DefaultLogName
+	//同时按日期和大小分割，文件名格式为 2006_01_02.1.log
+	if hook.LogConfig.DateSplit && hook.LogConfig.MaxLogSize > 0 {
+		seq, err := hook.resumeSegmentSeq()
+		if err != nil {
+			return err
+		}
+		// 因大小分割时FileSeq已经递增，队列中未写入的日志可能使文件大小暂未达到限制
+		if seq > hook.FileSeq {
+			hook.FileSeq = seq
+		}
+	} else if hook.LogConfig.MaxLogSize > 0 {
+		hook.FileSeq = hook.unusedSegmentSeq()
+	} else {
+		hook.FileSeq = 0
 	}
-	//按大小分割
-	if hook.LogConfig.MaxLogSize > 0 {
-		//按大小分割时，文件名格式为 2006_01_02_150405
-		tempFileName = time.Now().In(hook.LogConfig.TimeLocation).Format(hook.dateFmt2)
+
+	var err error
+	if !hook.LogConfig.ErrSeparate {
+		err = hook.openLogFile()
+	} else {
+		err = hook.openTwoLogFile()
 	}
-	//按日期分割
-	if hook.LogConfig.DateSplit {
-		tempFileName = hook.FileDate
+	if err != nil {
+		return err
 	}
+	hook.openRouteFiles()
+	hook.updateCurrentLinks()
+	return nil
+}
 
-	if !hook.LogConfig.ErrSeparate {
-		return hook.openLogFile(tempFileName)
+// 当前周期的普通日志文件的分段序号，其他文件返回false
+func (hook *logHook) currentSegmentSeq(name string) (int, bool) {
+	if hook.commonTmpl.implicitSeq {
+		// 默认的文件名，base.log、base.1.log...
+		return parseSegmentSeq(name, hook.commonTmpl.render(hook.fileTime, 0), hook.LogConfig.LogExt)
 	}
-	return hook.openTwoLogFile(tempFileName)
+	_, seq, ok := hook.parseLogFileName(name)
+	if !ok {
+		return 0, false
+	}
+	commonName, _ := hook.logFileNames(seq)
+	return seq, commonName == name
 }
 
-func (hook *logHook) openTwoLogFile(tempFileName string) error {
-	var errorFileName string
-	var commonFileName string
-	if hook.LogConfig.LogFileNameSuffix == "" {
-		errorFileName = tempFileName + "_" + "error" + hook.LogConfig.LogExt
-		commonFileName = tempFileName + hook.LogConfig.LogExt
-	} else {
-		errorFileName = tempFileName + "_" + "error" + "_" + hook.LogConfig.LogFileNameSuffix + hook.LogConfig.LogExt
-		commonFileName = tempFileName + "_" + hook.LogConfig.LogFileNameSuffix + hook.LogConfig.LogExt
+// 仅按大小分割时文件名中的时间精确到秒，返回当前时间未被使用的最小序号，
+// 避免同一秒内新建的文件与已有的文件同名
+func (hook *logHook) unusedSegmentSeq() int {
+	dir := hook.LogConfig.LogDir
+	if hook.LogConfig.ErrSeparate {
+		dir = filepath.Join(dir, hook.FileDate)
+	}
+	for seq := 0; ; seq++ {
+		commonName, errorName := hook.logFileNames(seq)
+		names := []string{commonName, errorName}
+		for _, route := range hook.routes {
+			names = append(names, route.tmpl.render(hook.fileTime, seq)+hook.LogConfig.LogExt)
+		}
+		used := slices.ContainsFunc(names, func(name string) bool {
+			path := filepath.Join(dir, name)
+			return doraemon.FileIsExist(path).IsTrue() || doraemon.FileIsExist(path+compressSuffix).IsTrue()
+		})
+		if !used {
+			return seq
+		}
+	}
+}
+
+// 同时按日期和大小分割时，找到当前周期最新的分段，若已达到大小限制则返回下一个分段序号。
+func (hook *logHook) resumeSegmentSeq() (int, error) {
+	dir := hook.LogConfig.LogDir
+	if hook.LogConfig.ErrSeparate {
+		dir = filepath.Join(hook.LogConfig.LogDir, hook.FileDate)
+		if !doraemon.DirIsExist(dir).IsTrue() {
+			return 0, nil
+		}
 	}
-	errorFileName = makeFileNameLegal(errorFileName)
-	commonFileName = makeFileNameLegal(commonFileName)
+	files, err := getFileNmaesInPath(dir)
+	if err != nil {
+		return 0, err
+	}
+	var latest = -1
+	var latestCompressed bool
+	for _, file := range files {
+		// 已压缩的分段不再写入
+		name := strings.TrimSuffix(file, compressSuffix)
+		seq, ok := hook.currentSegmentSeq(name)
+		if !ok || seq < latest {
+			continue
+		}
+		if seq > latest {
+			latestCompressed = false
+		}
+		latest = seq
+		latestCompressed = latestCompressed || name != file
+	}
+	if latest < 0 {
+		return 0, nil
+	}
+	if latestCompressed {
+		return latest + 1, nil
+	}
+	commonName, errorName := hook.logFileNames(latest)
+	var size int64
+	if info, err := os.Stat(filepath.Join(dir, commonName)); err == nil {
+		size += info.Size()
+	}
+	if hook.LogConfig.ErrSeparate {
+		if info, err := os.Stat(filepath.Join(dir, errorName)); err == nil {
+			size += info.Size()
+		}
+	}
+	if size >= hook.LogConfig.MaxLogSize {
+		return latest + 1, nil
+	}
+	return latest, nil
+}
+
+func (hook *logHook) openTwoLogFile() error {
+	commonFileName, errorFileName := hook.logFileNames(hook.FileSeq)
 
 	newPath := filepath.Join(hook.LogConfig.LogDir, hook.FileDate)
 	errorFileName = filepath.Join(newPath, errorFileName)
@@ -264,28 +515,22 @@ func (hook *logHook) openTwoLogFile(tempFileName string) error {
 
 	hook.ErrWriter = lazyFile
 	if lazyFile.IsCreated() {
-		hook.LogSize, _ = lazyFile.File().Seek(0, io.SeekEnd)
+		size, _ := lazyFile.File().Seek(0, io.SeekEnd)
+		hook.LogSize.Store(size)
 	} else {
-		hook.LogSize = 0
+		hook.LogSize.Store(0)
 	}
-	if hook.LogConfig.DisableWriterBuffer {
-		hook.OtherWriter = file2
-	} else {
+	hook.OtherWriter = file2
+	if !hook.LogConfig.DisableWriterBuffer {
 		hook.OtherBufWriter = bufio.NewWriterSize(file2, hook.WriterBufferSize)
 	}
 	tempSize, _ := file2.Seek(0, io.SeekEnd)
-	hook.LogSize += tempSize
+	hook.LogSize.Add(tempSize)
 	return nil
 }
 
-func (hook *logHook) openLogFile(tempFileName string) error {
-	var newFileName string
-	if hook.LogConfig.LogFileNameSuffix == "" {
-		newFileName = tempFileName + hook.LogConfig.LogExt
-	} else {
-		newFileName = tempFileName + "_" + hook.LogConfig.LogFileNameSuffix + hook.LogConfig.LogExt
-	}
-	newFileName = makeFileNameLegal(newFileName)
+func (hook *logHook) openLogFile() error {
+	newFileName, _ := hook.logFileNames(hook.FileSeq)
 	newFileName = filepath.Join(hook.LogConfig.LogDir, newFileName)
 
 	file, err := hook.tryOpenOldLogFile(newFileName)
@@ -293,21 +538,19 @@ func (hook *logHook) openLogFile(tempFileName string) error {
 		return err
 	}
 
-	if hook.LogConfig.DisableWriterBuffer {
-		hook.OtherWriter = file
-	} else {
+	hook.OtherWriter = file
+	if !hook.LogConfig.DisableWriterBuffer {
 		hook.OtherBufWriter = bufio.NewWriterSize(file, hook.WriterBufferSize)
 	}
 
 	//更新日志大小(文件为空时，返回0)
-	hook.LogSize, _ = file.Seek(0, io.SeekEnd)
+	size, _ := file.Seek(0, io.SeekEnd)
+	hook.LogSize.Store(size)
 	return nil
 }
 
 func (hook *logHook) tryOpenOldLogFile(newFileName string) (*os.File, error) {
-	if hook.LogConfig.DateSplit && hook.LogConfig.MaxLogSize > 0 {
-		return nil, errors.New("按日期分割和按大小分割不能同时开启")
-	}
+	// 按日期分割(同时按大小分割时，分段序号已在文件名中)
 	if hook.LogConfig.DateSplit {
 		return os.OpenFile(newFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	}
@@ -315,25 +558,34 @@ func (hook *logHook) tryOpenOldLogFile(newFileName string) (*os.File, error) {
 	if !hook.LogConfig.DateSplit && hook.LogConfig.MaxLogSize == 0 {
 		return os.OpenFile(newFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	}
+	// Rotate要求新建文件
+	if hook.newSegment {
+		return os.OpenFile(newFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	}
 
 	//按大小分割
 	oldLogFiles, err := getFileNmaesInPath(hook.LogConfig.LogDir)
 	if err != nil {
 		return nil, err
 	}
-	var latestLogFile string = hook.dateFmt2
+	var latestLogFile string
+	var latestLogFileTime time.Time
+	var latestLogFileSeq int
 
 	for _, file := range oldLogFiles {
-		if len(file) != len(filepath.Base(newFileName)) {
+		fileNameTime, seq, ok := hook.parseLogFileName(file)
+		if !ok {
 			continue
 		}
-		fileNameTime := file[0:len(hook.dateFmt2)]
-		latestLogFileTime := latestLogFile[0:len(hook.dateFmt2)]
-		if timeStringCompare(fileNameTime, latestLogFileTime, hook.dateFmt2) == 1 {
+		// 同一秒内创建的文件，序号大的较新
+		if latestLogFile == "" || fileNameTime.After(latestLogFileTime) ||
+			(fileNameTime.Equal(latestLogFileTime) && seq > latestLogFileSeq) {
 			latestLogFile = file
+			latestLogFileTime = fileNameTime
+			latestLogFileSeq = seq
 		}
 	}
-	if latestLogFile == hook.dateFmt2 {
+	if latestLogFile == "" {
 		return os.OpenFile(newFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	}
 	// 检查文件大小
@@ -342,6 +594,9 @@ func (hook *logHook) tryOpenOldLogFile(newFileName string) (*os.File, error) {
 		return nil, err
 	}
 	if fileStat.Size() < hook.LogConfig.MaxLogSize {
+		// 续写旧文件，路由的文件名使用旧文件的时间和序号
+		hook.fileTime = latestLogFileTime
+		hook.FileSeq = latestLogFileSeq
 		return os.OpenFile(filepath.Join(hook.LogConfig.LogDir, latestLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	}
 	// 文件大小超过限制，新建文件
@@ -349,7 +604,7 @@ func (hook *logHook) tryOpenOldLogFile(newFileName string) (*os.File, error) {
 }
 
 func (hook *logHook) tryOpenTwoOldLogFile(errorFileName, commonFileName string) (*doraemon.LazyFileWriter, *os.File, bool, error) {
-	if hook.LogConfig.MaxLogSize == 0 {
+	if hook.LogConfig.MaxLogSize == 0 || hook.LogConfig.DateSplit || hook.newSegment {
 		return nil, nil, false, nil
 	}
 	dirs, err := getFolderNamesInPath(hook.LogConfig.LogDir)
@@ -386,133 +641,48 @@ func (hook *logHook) tryOpenTwoOldLogFile(errorFileName, commonFileName string)
 	return file, file2, true, nil
 }
 
-func (hook *logHook) deleteOldLogTimer() {
-	hook.deleteOldLogOnce(hook.LogConfig.MaxKeepDays)
-
-	ticker := time.NewTicker(time.Hour * 24)
-	defer ticker.Stop()
-	for range ticker.C {
-		hook.deleteOldLogOnce(hook.LogConfig.MaxKeepDays)
-	}
-}
-
-// 删除过期日志(n<=0时删除所有)
-func (hook *logHook) deleteOldLogOnce(n int) {
-	if hook.LogConfig.LogDir == "" {
-		// 仅支持删除文件夹中的日志
-		return
-	}
-	if n <= 0 {
-		// return
-		hook.WriterLock.Lock()
-		if hook.OtherBufWriter != nil {
-			hook.OtherBufWriter.Flush()
+// 停止后台goroutine，写入队列中剩余的日志，刷新并同步到磁盘后关闭文件。可重复调用。
+func (hook *logHook) shutdown(ctx context.Context) error {
+	hook.closeOnce.Do(func() {
+		var errs []error
+		removeManagedHook(hook)
+		hook.cancel()
+		done := make(chan struct{})
+		go func() {
+			hook.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			errs = append(errs, ctx.Err())
 		}
-		if hook.ErrWriter != nil && hook.ErrWriter.IsCreated() {
-			path := hook.ErrWriter.Name()
-			hook.ErrWriter.Close()
-			err := os.Remove(path)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "deleteOldLog os.Remove err:%v", err)
-			}
-		}
-		if hook.OtherWriter != nil {
-			path := hook.OtherWriter.Name()
-			hook.OtherWriter.Close()
-			err := os.Remove(path)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "deleteOldLog os.Remove err:%v", err)
-			}
-		}
-		hook.updateNewLogPathAndFile()
-		hook.WriterLock.Unlock()
-	}
-	hook.deleteOldLogDirOnce(hook.LogConfig.LogDir, n)
-	hook.deleteOldLogFileOnce(hook.LogConfig.LogDir, n)
-}
+		// 输出未输出的重复统计
+		hook.flushRepeated(true)
 
-// 删除文件夹中n天前的日志文件夹。
-// 由于调用了logrus.Errorf，所以不要对此方法加锁，否则会死锁。
-func (hook *logHook) deleteOldLogDirOnce(dir string, n int) {
-	// if n <= 0 {
-	// 	return
-	// }
-	dirs, err := getFolderNamesInPath(dir)
-	if err != nil {
-		logrus.Errorf("deleteOldLog getDirs err:%v", err)
-		return
-	}
-	for _, dir := range dirs {
-		if strings.HasSuffix(dir, hook.LogConfig.keepSuffix) {
-			continue
+		if err := hook.file.Close(); err != nil {
+			errs = append(errs, err)
 		}
-		var dirNameTime = dir
-		if len(dir) > len(hook.dateFmt) {
-			dirNameTime = dir[0:len(hook.dateFmt)]
+		// 文件关闭后再等待Sink，不会因为Sink阻塞而丢失文件中的日志
+		if err := hook.closeSinks(ctx); err != nil {
+			errs = append(errs, err)
 		}
-		date, err := time.Parse(hook.dateFmt, dirNameTime)
-		if err != nil {
-			// logrus.Errorf("deleteOldLog time.Parse err:%v", err)
-			// can not parse dir to time, ignore
-			continue
-		}
-		if time.Since(date).Hours() > float64(n*24) {
-			dirPath := filepath.Join(hook.LogConfig.LogDir, dir)
-			hook.deleteOldLogFileOnce(dirPath, n)
-			//if dir is empty
-			if isEmptyDir(dirPath) {
-				err := os.Remove(dirPath)
-				if err != nil {
-					logrus.Errorf("deleteOldLogDir os.Remove err:%v", err)
-				}
-			}
-		}
-	}
-}
 
-// 删除文件夹中n天前的日志文件。
-// 由于调用了logrus.Errorf，所以不要对此方法加锁，否则会死锁。
-func (hook *logHook) deleteOldLogFileOnce(dir string, n int) {
-	// if n <= 0 {
-	// 	return
-	// }
-	files, err := getFileNmaesInPath(dir)
-	if err != nil {
-		logrus.Errorf("deleteOldLog getFiles err:%v", err)
-		return
-	}
-	// var ErrWriterFilePath string
-	// var OthWriterFilePath string
-	// if hook.ErrWriter != nil {
-	// 	ErrWriterFilePath, _ = filepath.Abs(hook.ErrWriter.Name())
-	// }
-	// if hook.OtherWriter != nil {
-	// 	OthWriterFilePath, _ = filepath.Abs(hook.OtherWriter.Name())
-	// }
-	for _, fileName := range files {
-		if strings.HasSuffix(fileName, hook.LogConfig.keepSuffix) {
-			continue
-		}
-		// fileAbsPath, _ := filepath.Abs(filepath.Join(dir, fileName))
-		// fileAbsPath = strings.ToLower(fileAbsPath)
-		tempFileName := strings.ToLower(fileName)
-		if hook.ErrWriter != nil && tempFileName == strings.ToLower(filepath.Base(hook.ErrWriter.Name())) {
-			continue
-		}
-		if hook.OtherWriter != nil && tempFileName == strings.ToLower(filepath.Base(hook.OtherWriter.Name())) {
-			continue
-		}
-		// 最后修改时间
-		fileInfo, err := os.Stat(filepath.Join(dir, fileName))
-		if err != nil {
-			logrus.Errorf("deleteOldLog os.Stat err:%v", err)
-			continue
-		}
-		if time.Since(fileInfo.ModTime()).Hours() > float64(n*24) {
-			err := os.Remove(filepath.Join(dir, fileName))
-			if err != nil {
-				logrus.Errorf("deleteOldLog os.Remove err:%v", err)
+		// 等待分割后的后台任务完成
+		splitDone := make(chan struct{})
+		go func() {
+			hook.splitWg.Wait()
+			close(splitDone)
+		}()
+		select {
+		case <-splitDone:
+		case <-ctx.Done():
+			if len(errs) == 0 {
+				errs = append(errs, ctx.Err())
 			}
 		}
-	}
+		releaseLogDir(hook.LogConfig.LogDir)
+		hook.closeErr = errors.Join(errs...)
+	})
+	return hook.closeErr
 }
@@ -1,6 +1,12 @@
 package mylog
 
-import "testing"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
 
 func Test_timeStringCompare(t *testing.T) {
 	type args struct {
@@ -26,3 +32,57 @@ func Test_timeStringCompare(t *testing.T) {
 		})
 	}
 }
+
+func TestDateAndSizeSplit(t *testing.T) {
+	dir := t.TempDir()
+	config := LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		DateSplit:           true,
+		MaxLogSize:          1024,
+		DisableWriterBuffer: true,
+	}
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		logger.Info("0123456789012345678901234567890123456789")
+	}
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	today := time.Now().Format("2006_01_02")
+	files, _ := getFileNmaesInPath(dir)
+	var latest = -1
+	for seq := 0; seq < len(files); seq++ {
+		name := today + ".log"
+		if seq > 0 {
+			name = fmt.Sprintf("%s.%d.log", today, seq)
+		}
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			break
+		}
+		latest = seq
+	}
+	if latest != len(files)-1 {
+		t.Errorf("unexpected files %v", files)
+	}
+	if latest < 1 {
+		t.Fatalf("expected several segments, got %v", files)
+	}
+
+	// resume writing to the newest segment after restart
+	logger, err = NewLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := findLogHooks(logger)[0]
+	if hook.FileSeq != latest && hook.FileSeq != latest+1 {
+		t.Errorf("resumed segment = %d, latest = %d", hook.FileSeq, latest)
+	}
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,195 @@
+package mylog
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OverflowPolicy decides what happens to an entry when the async write queue is full.
+// Panic and fatal entries are never dropped, the oldest entry is dropped to make room for them.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock blocks the logging goroutine until there is room in the queue.
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropNewest drops the entry being logged.
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowDropOldest drops the oldest entry in the queue.
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowDropBelowLevel drops the entry being logged if it is less severe than LogConfig.OverflowLevel,
+	// the other entries block.
+	OverflowDropBelowLevel OverflowPolicy = "drop_below_level"
+)
+
+const defaultQueueSize = 8192
+
+func validateOverflow(c LogConfig) error {
+	switch c.OverflowPolicy {
+	case "", OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowDropBelowLevel:
+	default:
+		return fmt.Errorf("invalid OverflowPolicy %q", c.OverflowPolicy)
+	}
+	if c.QueueSize < 0 {
+		return fmt.Errorf("QueueSize must not be negative: %d", c.QueueSize)
+	}
+	if c.OverflowLevel != "" {
+		if _, err := parseLevelStrict(c.OverflowLevel); err != nil {
+			return fmt.Errorf("OverflowLevel: %w", err)
+		}
+	}
+	return nil
+}
+
+// QueueStats is the state of the async write queue of a logger.
+type QueueStats struct {
+	// Entries waiting to be written
+	Len int
+	// Maximum number of entries in the queue
+	Capacity int
+	// Entries dropped because the queue was full
+	Dropped uint64
+	// Dropped entries by level, levels without dropped entries are omitted
+	DroppedByLevel map[string]uint64
+}
+
+// GetQueueStats returns the state of the async write queue of the mylog hook attached to the logger.
+func GetQueueStats(logger *logrus.Logger) QueueStats {
+	hooks := findLogHooks(logger)
+	if len(hooks) == 0 {
+		return QueueStats{}
+	}
+	return hooks[0].bufferQueue.stats()
+}
+
+type queuedLine struct {
+	line  []byte
+	level logrus.Level
+}
+
+// 有界的写入队列，满时按OverflowPolicy处理
+type lineQueue struct {
+	mu      sync.Mutex
+	notFull *sync.Cond
+	lines   []queuedLine
+	// 复用已写入的批次
+	spare    []queuedLine
+	capacity int
+	policy   OverflowPolicy
+	level    logrus.Level
+	// 关闭后不再阻塞
+	closed bool
+	// 队列由空变为非空时通知bufferFlusher
+	signal  chan struct{}
+	dropped [logrus.TraceLevel + 1]atomic.Uint64
+}
+
+func newLineQueue(config LogConfig) *lineQueue {
+	q := &lineQueue{signal: make(chan struct{}, 1)}
+	q.notFull = sync.NewCond(&q.mu)
+	q.setConfig(config)
+	return q
+}
+
+func (q *lineQueue) setConfig(config LogConfig) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.capacity = config.QueueSize
+	if q.capacity <= 0 {
+		q.capacity = defaultQueueSize
+	}
+	q.policy = config.OverflowPolicy
+	if q.policy == "" {
+		q.policy = OverflowBlock
+	}
+	q.level = logrus.WarnLevel
+	if config.OverflowLevel != "" {
+		q.level = PraseLevel(config.OverflowLevel)
+	}
+	// 容量可能变大
+	q.notFull.Broadcast()
+}
+
+// 返回日志是否入队，队列满时可能阻塞。panic和fatal的日志总是入队
+func (q *lineQueue) push(line []byte, level logrus.Level) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.lines) >= q.capacity && !q.closed {
+		switch {
+		case q.policy != OverflowBlock && level <= logrus.FatalLevel:
+			// panic和fatal的日志说明了退出的原因，不丢弃，改为丢弃最旧的日志
+			q.dropped[q.lines[0].level].Add(1)
+			q.lines[0] = queuedLine{}
+			q.lines = q.lines[1:]
+		case q.policy == OverflowDropNewest, q.policy == OverflowDropBelowLevel && level > q.level:
+			q.dropped[level].Add(1)
+			return false
+		case q.policy == OverflowDropOldest:
+			q.dropped[q.lines[0].level].Add(1)
+			q.lines[0] = queuedLine{}
+			q.lines = q.lines[1:]
+		default:
+			q.notFull.Wait()
+		}
+	}
+	q.lines = append(q.lines, queuedLine{line: line, level: level})
+	if len(q.lines) == 1 {
+		select {
+		case q.signal <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// 取出所有日志，队列为空时返回nil
+func (q *lineQueue) popAll() []queuedLine {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.lines) == 0 {
+		return nil
+	}
+	lines := q.lines
+	q.lines, q.spare = q.spare[:0], nil
+	q.notFull.Broadcast()
+	return lines
+}
+
+func (q *lineQueue) recycle(lines []queuedLine) {
+	clear(lines)
+	q.mu.Lock()
+	q.spare = lines[:0]
+	q.mu.Unlock()
+}
+
+func (q *lineQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.lines)
+}
+
+// 唤醒阻塞的goroutine，之后入队不再阻塞
+func (q *lineQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.notFull.Broadcast()
+	q.mu.Unlock()
+}
+
+func (q *lineQueue) stats() QueueStats {
+	q.mu.Lock()
+	stats := QueueStats{Len: len(q.lines), Capacity: q.capacity}
+	q.mu.Unlock()
+	for level := range q.dropped {
+		if n := q.dropped[level].Load(); n > 0 {
+			if stats.DroppedByLevel == nil {
+				stats.DroppedByLevel = make(map[string]uint64)
+			}
+			stats.DroppedByLevel[logrus.Level(level).String()] = n
+			stats.Dropped += n
+		}
+	}
+	return stats
+}
@@ -0,0 +1,165 @@
+package mylog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func queueContent(lines []queuedLine) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.Write(l.line)
+	}
+	return b.String()
+}
+
+func Test_lineQueue(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  OverflowPolicy
+		want    string
+		dropped map[string]uint64
+	}{
+		{"drop newest", OverflowDropNewest, "ab", map[string]uint64{"info": 1, "error": 1}},
+		{"drop oldest", OverflowDropOldest, "cd", map[string]uint64{"info": 2}},
+		{"drop below level", OverflowDropBelowLevel, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newLineQueue(LogConfig{QueueSize: 2, OverflowPolicy: tt.policy})
+			q.push([]byte("a"), logrus.InfoLevel)
+			q.push([]byte("b"), logrus.InfoLevel)
+			q.push([]byte("c"), logrus.InfoLevel)
+			if tt.policy == OverflowDropBelowLevel {
+				stats := q.stats()
+				if stats.Len != 2 || stats.Dropped != 1 || stats.DroppedByLevel["info"] != 1 {
+					t.Fatalf("stats = %+v", stats)
+				}
+				return
+			}
+			q.push([]byte("d"), logrus.ErrorLevel)
+			if got := queueContent(q.popAll()); got != tt.want {
+				t.Errorf("queue = %q, want %q", got, tt.want)
+			}
+			stats := q.stats()
+			if stats.Len != 0 || stats.Capacity != 2 || len(stats.DroppedByLevel) != len(tt.dropped) {
+				t.Errorf("stats = %+v", stats)
+			}
+			for level, n := range tt.dropped {
+				if stats.DroppedByLevel[level] != n {
+					t.Errorf("dropped %s = %d, want %d", level, stats.DroppedByLevel[level], n)
+				}
+			}
+		})
+	}
+}
+
+func Test_lineQueueBlock(t *testing.T) {
+	q := newLineQueue(LogConfig{QueueSize: 1, OverflowPolicy: OverflowDropBelowLevel, OverflowLevel: "error"})
+	q.push([]byte("a"), logrus.InfoLevel)
+	done := make(chan bool)
+	go func() {
+		// 错误日志不会被丢弃，等待队列有空间
+		done <- q.push([]byte("b"), logrus.ErrorLevel)
+	}()
+	select {
+	case <-done:
+		t.Fatal("push should block when the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := queueContent(q.popAll()); got != "a" {
+		t.Errorf("queue = %q", got)
+	}
+	if !<-done {
+		t.Error("blocked entry should be queued")
+	}
+	if got := queueContent(q.popAll()); got != "b" {
+		t.Errorf("queue = %q", got)
+	}
+
+	q.push([]byte("c"), logrus.ErrorLevel)
+	go func() { done <- q.push([]byte("d"), logrus.ErrorLevel) }()
+	time.Sleep(10 * time.Millisecond)
+	q.close()
+	if !<-done {
+		t.Error("closing the queue should wake blocked producers")
+	}
+}
+
+func TestAsyncErrWriter(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:      dir,
+		NoConsole:   true,
+		ErrSeparate: true,
+		QueueSize:   16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		logger.Error("async error")
+	}
+	logger.Info("done")
+	if stats := GetQueueStats(logger); stats.Capacity != 16 || stats.Dropped != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+	folders, _ := getFolderNamesInPath(dir)
+	if len(folders) != 1 {
+		t.Fatalf("folders = %v", folders)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, folders[0], "default_error.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(content), "async error"); n != 100 {
+		t.Errorf("error log has %d entries, want 100", n)
+	}
+}
+
+func TestFatalNotDropped(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:           dir,
+		NoConsole:        true,
+		QueueSize:        2,
+		OverflowPolicy:   OverflowDropNewest,
+		WriterBufferSize: 1 << 20,
+		FlushInterval:    time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	logger.ExitFunc = func(int) {}
+
+	// 持有写锁使bufferFlusher无法写入，填满队列
+	hook := findLogHooks(logger)[0]
+	hook.WriterLock.Lock()
+	hook.bufferQueue.push([]byte("filler 1\n"), logrus.InfoLevel)
+	hook.bufferQueue.push([]byte("filler 2\n"), logrus.InfoLevel)
+	if hook.bufferQueue.push([]byte("dropped\n"), logrus.InfoLevel) {
+		t.Error("the queue should be full")
+	}
+	if !hook.bufferQueue.push([]byte("fatal 1\n"), logrus.FatalLevel) {
+		t.Error("fatal entries should not be dropped")
+	}
+	hook.WriterLock.Unlock()
+
+	logger.Fatal("fatal reason")
+	content := readLog(t, filepath.Join(dir, "default.log"))
+	if !strings.Contains(content, "fatal 1") || !strings.Contains(content, "fatal reason") {
+		t.Errorf("log = %q, want the fatal entries", content)
+	}
+	if stats := GetQueueStats(logger); stats.DroppedByLevel["info"] < 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
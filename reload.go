@@ -0,0 +1,273 @@
+package mylog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/doraemonkeys/doraemon"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateConfig applies config to a logger created by NewLogger or InitGlobalLogger while it is in use.
+//
+// The config is checked like NewLogger does, a config that NewLogger rejects is rejected and the running
+// config is kept. Call Validate first for the stricter checks of LoadConfig and ConfigFromEnv.
+// Level, formatter options, caller and console settings of the logger are only changed
+// when they differ from the running config, so settings changed directly on the logger are kept.
+// Retention and buffering take effect immediately. Sinks can not be changed, the running sinks are kept. Changes that need new log files
+// (LogDir, split mode, file names, ErrSeparate, buffering) close the current files and
+// open new ones, just like a rotation. If the key set by SetKeyValue is empty, the running key and value are kept.
+// If FieldProviders is nil (e.g. a config from LoadConfig) the running providers are kept, set an empty map to remove them.
+func UpdateConfig(logger *logrus.Logger, config LogConfig) error {
+	hooks := findLogHooks(logger)
+	if len(hooks) == 0 {
+		return errors.New("no mylog hook found in the logger")
+	}
+	if len(hooks) > 1 {
+		return errors.New("more than one mylog hook found in the logger")
+	}
+	return hooks[0].updateConfig(logger, config)
+}
+
+func (hook *logHook) updateConfig(logger *logrus.Logger, config LogConfig) error {
+	if err := normalizeConfig(&config); err != nil {
+		return err
+	}
+	// 先用新配置生成文件名模板，失败时不影响正在使用的配置
+	tmp := &logHook{LogConfig: config, dateFmt2: sizeSplitTimeFmt}
+	if err := tmp.initFileNameTemplates(); err != nil {
+		return err
+	}
+
+	// 与压缩和清理日志互斥
+	hook.splitMu.Lock()
+	defer hook.splitMu.Unlock()
+
+	var old LogConfig
+	err := func() error {
+		hook.WriterLock.Lock()
+		defer hook.WriterLock.Unlock()
+		if hook.closed {
+			return errors.New("the logger has been closed")
+		}
+		old = hook.LogConfig
+		// Sink只在创建logger时启动
+		config.Sinks = old.Sinks
+		if config.key == "" {
+			config.key, config.value = old.key, old.value
+		}
+		// FieldProviders不能从配置文件加载
+		if config.FieldProviders == nil {
+			config.FieldProviders = old.FieldProviders
+		}
+		if !needNewLogFiles(old, config) {
+			hook.LogConfig = config
+			hook.afterSplit(nil)
+			return nil
+		}
+
+		dirChanged := filepath.Clean(old.LogDir) != filepath.Clean(config.LogDir)
+		if dirChanged {
+			if err := acquireLogDir(config.LogDir); err != nil {
+				return err
+			}
+		}
+		// 队列中的日志属于旧文件
+		hook.drainQueue()
+		if hook.OtherBufWriter != nil {
+			hook.OtherBufWriter.Flush()
+		}
+
+		// 打开新文件失败时恢复
+		saved := savedLogFiles{
+			config: hook.LogConfig, bufferSize: hook.WriterBufferSize, period: hook.period, dateFmt: hook.dateFmt,
+			commonTmpl: hook.commonTmpl, errorTmpl: hook.errorTmpl, routes: hook.routes,
+			errWriter: hook.ErrWriter, routeWriters: hook.RouteWriters, otherWriter: hook.OtherWriter, otherBufWriter: hook.OtherBufWriter,
+			fileDate: hook.FileDate, fileSeq: hook.FileSeq, fileTime: hook.fileTime, logSize: hook.LogSize.Load(),
+		}
+		hook.LogConfig = config
+		hook.WriterBufferSize = config.WriterBufferSize
+		if hook.WriterBufferSize <= 0 {
+			hook.WriterBufferSize = 4096
+		}
+		hook.period, hook.dateFmt = tmp.period, tmp.dateFmt
+		hook.commonTmpl, hook.errorTmpl, hook.routes = tmp.commonTmpl, tmp.errorTmpl, tmp.routes
+		hook.ErrWriter, hook.RouteWriters, hook.OtherWriter, hook.OtherBufWriter = nil, nil, nil, nil
+		hook.FileSeq = 0
+		if err := hook.updateNewLogPathAndFile(); err != nil {
+			hook.LogConfig, hook.WriterBufferSize = saved.config, saved.bufferSize
+			hook.period, hook.dateFmt = saved.period, saved.dateFmt
+			hook.commonTmpl, hook.errorTmpl, hook.routes = saved.commonTmpl, saved.errorTmpl, saved.routes
+			hook.ErrWriter, hook.RouteWriters = saved.errWriter, saved.routeWriters
+			hook.OtherWriter, hook.OtherBufWriter = saved.otherWriter, saved.otherBufWriter
+			hook.FileDate, hook.FileSeq, hook.fileTime = saved.fileDate, saved.fileSeq, saved.fileTime
+			hook.LogSize.Store(saved.logSize)
+			if dirChanged {
+				releaseLogDir(config.LogDir)
+			}
+			return fmt.Errorf("updateNewLogPathAndFile err:%v", err)
+		}
+
+		var closedFiles []string
+		closedFiles = closeLazyWriters(closedFiles, saved.errWriter)
+		closedFiles = closeLazyWriters(closedFiles, saved.routeWriters...)
+		if saved.otherWriter != nil {
+			closedFiles = append(closedFiles, saved.otherWriter.Name())
+			saved.otherWriter.Close()
+		}
+		if dirChanged {
+			releaseLogDir(old.LogDir)
+		}
+		hook.afterSplit(closedFiles)
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	// 不要在持有WriterLock时修改logger
+	filtered := hook.filtered()
+	rulesChanged := !slices.Equal(old.LevelRules, config.LevelRules)
+	if rulesChanged {
+		hook.levelRules.Store(newLevelRules(config.LevelRules))
+	}
+	if samplingChanged(old, config) {
+		hook.sampler.Store(newSampler(config))
+	}
+	if old.QueueSize != config.QueueSize || old.OverflowPolicy != config.OverflowPolicy || old.OverflowLevel != config.OverflowLevel {
+		hook.bufferQueue.setConfig(config)
+	}
+	if dedupChanged(old, config) {
+		hook.flushRepeated(true)
+		hook.fileDedup.Store(newDeduper(config.DedupFile, config.DedupWindow))
+		hook.consoleDedup.Store(newDeduper(config.DedupConsole, config.DedupWindow))
+	}
+	if config.LogLevel != old.LogLevel || rulesChanged {
+		hook.setLevel(logger, PraseLevel(config.LogLevel))
+	}
+	if config.DisableCaller != old.DisableCaller {
+		logger.SetReportCaller(!config.DisableCaller)
+	}
+	if formatterChanged(old, config) || filtered != hook.filtered() {
+		hook.setFormatter(logger, newFormatter(config))
+	}
+	if config.NoConsole != old.NoConsole {
+		if config.NoConsole {
+			hook.consoleOut = logger.Out
+			logger.SetOutput(io.Discard)
+		} else if hook.consoleOut != nil {
+			logger.SetOutput(hook.consoleOut)
+		}
+	}
+	return nil
+}
+
+// UpdateConfig重新打开日志文件前的状态
+type savedLogFiles struct {
+	config     LogConfig
+	bufferSize int
+	period     RotationPeriod
+	dateFmt    string
+	commonTmpl *fileNameTemplate
+	errorTmpl  *fileNameTemplate
+	routes     []logRoute
+
+	errWriter      *doraemon.LazyFileWriter
+	routeWriters   []*doraemon.LazyFileWriter
+	otherWriter    *os.File
+	otherBufWriter *bufio.Writer
+
+	fileDate string
+	fileSeq  int
+	fileTime time.Time
+	logSize  int64
+}
+
+// 修改这些配置需要重新打开日志文件
+func needNewLogFiles(old, config LogConfig) bool {
+	return filepath.Clean(old.LogDir) != filepath.Clean(config.LogDir) ||
+		old.LogFileNameSuffix != config.LogFileNameSuffix ||
+		old.DefaultLogName != config.DefaultLogName ||
+		old.ErrSeparate != config.ErrSeparate ||
+		old.DateSplit != config.DateSplit ||
+		old.RotationPeriod != config.RotationPeriod ||
+		old.RotationMinutes != config.RotationMinutes ||
+		old.LogFileNameTemplate != config.LogFileNameTemplate ||
+		old.LogFileDisable != config.LogFileDisable ||
+		old.DisableWriterBuffer != config.DisableWriterBuffer ||
+		old.WriterBufferSize != config.WriterBufferSize ||
+		(old.MaxLogSize > 0) != (config.MaxLogSize > 0) ||
+		old.LogExt != config.LogExt ||
+		old.CurrentLink != config.CurrentLink ||
+		!slices.EqualFunc(old.Routes, config.Routes, func(a, b Route) bool {
+			return a.Name == b.Name && a.Exclusive == b.Exclusive && slices.Equal(a.Levels, b.Levels)
+		}) ||
+		old.TimeLocation.String() != config.TimeLocation.String()
+}
+
+func formatterChanged(old, config LogConfig) bool {
+	return old.JSONFormat != config.JSONFormat ||
+		old.TimestampFormat != config.TimestampFormat ||
+		old.NoTimestamp != config.NoTimestamp ||
+		old.DisableColors != config.DisableColors ||
+		old.DisableLevelTruncation != config.DisableLevelTruncation ||
+		old.PadLevelText != config.PadLevelText
+}
+
+// WatchConfigFile polls the JSON config file at path (see LoadConfig) every interval,
+// default is 2 seconds, and applies it to the logger with UpdateConfig whenever
+// its modification time or size changes. The file is not applied when the watcher starts.
+//
+// Invalid files are reported through the logger and the running config is kept.
+// The watcher stops when stop is called or the logger is closed.
+func WatchConfigFile(logger *logrus.Logger, path string, interval time.Duration) (stop func(), err error) {
+	hooks := findLogHooks(logger)
+	if len(hooks) == 0 {
+		return nil, errors.New("no mylog hook found in the logger")
+	}
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	modTime, size := info.ModTime(), info.Size()
+
+	done := make(chan struct{})
+	var once sync.Once
+	stop = func() { once.Do(func() { close(done) }) }
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-hooks[0].ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(path)
+			if err != nil || (info.ModTime().Equal(modTime) && info.Size() == size) {
+				continue
+			}
+			modTime, size = info.ModTime(), info.Size()
+			config, err := LoadConfig(path)
+			if err == nil {
+				err = UpdateConfig(logger, config)
+			}
+			if err != nil {
+				logger.Errorf("reload log config %s err:%v", path, err)
+			}
+		}
+	}()
+	return stop, nil
+}
@@ -0,0 +1,183 @@
+package mylog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestUpdateConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := LogConfig{
+		LogDir:    filepath.Join(dir, "a"),
+		NoConsole: true,
+		LogLevel:  InfoLevel,
+	}
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+
+	logger.Debug("dropped")
+	config.LogLevel = DebugLevel
+	if err := UpdateConfig(logger, config); err != nil {
+		t.Fatalf("UpdateConfig() err = %v", err)
+	}
+	if logger.GetLevel() != logrus.DebugLevel {
+		t.Fatalf("level = %v, want debug", logger.GetLevel())
+	}
+	logger.Debug("kept")
+
+	// 无效的配置不影响正在使用的配置
+	invalid := config
+	invalid.LogLevel = TraceLevel
+	invalid.OverflowPolicy = "drop_all"
+	if err := UpdateConfig(logger, invalid); err == nil {
+		t.Fatal("UpdateConfig() with invalid OverflowPolicy should fail")
+	}
+	if logger.GetLevel() != logrus.DebugLevel {
+		t.Fatalf("level = %v after invalid update, want debug", logger.GetLevel())
+	}
+
+	// 修改LogDir会重新打开文件
+	moved := config
+	moved.LogDir = filepath.Join(dir, "b")
+	moved.ErrSeparate = true
+	if err := UpdateConfig(logger, moved); err != nil {
+		t.Fatalf("UpdateConfig() err = %v", err)
+	}
+	logger.Info("moved")
+	logger.Error("oops")
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "a", "default.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "dropped") || !strings.Contains(string(content), "kept") ||
+		strings.Contains(string(content), "moved") {
+		t.Errorf("old log = %q", content)
+	}
+	folders, err := getFolderNamesInPath(filepath.Join(dir, "b"))
+	if err != nil || len(folders) != 1 {
+		t.Fatalf("folders = %v, err = %v", folders, err)
+	}
+	content, err = os.ReadFile(filepath.Join(dir, "b", folders[0], "default.log"))
+	if err != nil || !strings.Contains(string(content), "moved") {
+		t.Errorf("new log = %q, err = %v", content, err)
+	}
+	content, err = os.ReadFile(filepath.Join(dir, "b", folders[0], "default_error.log"))
+	if err != nil || !strings.Contains(string(content), "oops") {
+		t.Errorf("new error log = %q, err = %v", content, err)
+	}
+
+	// 旧的目录已释放
+	logger2, err := NewLogger(config)
+	if err != nil {
+		t.Fatalf("reuse old logDir err = %v", err)
+	}
+	Close(logger2)
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.json")
+	logDir := filepath.ToSlash(filepath.Join(dir, "logs"))
+	write := func(level string) {
+		data := `{"LogDir": "` + logDir + `", "NoConsole": true, "LogLevel": "` + level + `"}`
+		if err := os.WriteFile(path, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(InfoLevel)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+
+	stop, err := WatchConfigFile(logger, path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	write(TraceLevel)
+	deadline := time.Now().Add(5 * time.Second)
+	for logger.GetLevel() != logrus.TraceLevel {
+		if time.Now().After(deadline) {
+			t.Fatalf("level = %v, want trace", logger.GetLevel())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUpdateConfigKeepsFieldProviders(t *testing.T) {
+	dir := t.TempDir()
+	config := LogConfig{
+		LogDir:         dir,
+		NoConsole:      true,
+		JSONFormat:     true,
+		FieldProviders: map[string]FieldProvider{"pid": PidProvider()},
+	}
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+
+	// 与WatchConfigFile一样使用从文件加载的配置
+	reloaded := config
+	reloaded.FieldProviders = nil
+	reloaded.LogLevel = DebugLevel
+	if err := UpdateConfig(logger, reloaded); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("kept")
+	reloaded.FieldProviders = map[string]FieldProvider{}
+	if err := UpdateConfig(logger, reloaded); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("removed")
+	Sync(logger)
+
+	lines := strings.Split(strings.TrimSpace(readLog(t, filepath.Join(dir, "default.log"))), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"pid":`) || strings.Contains(lines[1], `"pid":`) {
+		t.Errorf("log = %q", lines)
+	}
+}
+
+func TestUpdateConfigSameRulesAsNewLogger(t *testing.T) {
+	// NewLogger接受但Validate不接受的配置，UpdateConfig同样接受
+	for _, config := range []LogConfig{
+		{LogFileDisable: true, NoConsole: true, DateSplit: true},
+		{LogDir: t.TempDir(), NoConsole: true, RotationPeriod: RotateHourly},
+	} {
+		if config.Validate() == nil {
+			t.Fatalf("Validate(%+v) should fail", config)
+		}
+		logger, err := NewLogger(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.LogLevel = DebugLevel
+		if err := UpdateConfig(logger, config); err != nil {
+			t.Errorf("UpdateConfig() err = %v", err)
+		}
+		if logger.GetLevel() != logrus.DebugLevel {
+			t.Errorf("level = %v, want debug", logger.GetLevel())
+		}
+		Close(logger)
+	}
+}
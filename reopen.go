@@ -0,0 +1,234 @@
+package mylog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/doraemonkeys/doraemon"
+	"github.com/sirupsen/logrus"
+)
+
+// Rotate closes the active log files and starts new ones like a size split does, then compresses
+// and cleans up the closed files according to the config. Without MaxLogSize the file names
+// do not change, so the same files are reopened like Reopen does. When splitting only by size
+// the file names contain the creation time in seconds, a new file created within the second
+// of the active files gets a sequence suffix, e.g. 2006_01_02_150405.1.log.
+func Rotate(logger *logrus.Logger) error {
+	var errs []error
+	for _, hook := range findLogHooks(logger) {
+		if err := hook.rotate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reopen flushes and closes the active log files and opens the files at the same paths again,
+// creating them if they were moved or deleted, e.g. by logrotate with the create option.
+func Reopen(logger *logrus.Logger) error {
+	var errs []error
+	for _, hook := range findLogHooks(logger) {
+		if err := hook.reopen(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReopenOnSignal calls Reopen when one of the signals (SIGHUP by default) is received,
+// e.g. from the postrotate script of logrotate. Call stop to stop handling the signals.
+func ReopenOnSignal(logger *logrus.Logger, signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	return onSignal(signals, func() {
+		if err := Reopen(logger); err != nil {
+			fmt.Fprintln(os.Stderr, "mylog: reopen err:", err)
+		}
+	})
+}
+
+// RotateOnSignal calls Rotate when one of the signals (e.g. syscall.SIGUSR1) is received.
+// Nothing is handled when no signal is given. Call stop to stop handling the signals.
+func RotateOnSignal(logger *logrus.Logger, signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		return func() {}
+	}
+	return onSignal(signals, func() {
+		if err := Rotate(logger); err != nil {
+			fmt.Fprintln(os.Stderr, "mylog: rotate err:", err)
+		}
+	})
+}
+
+func onSignal(signals []os.Signal, fn func()) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ch:
+				fn()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+func (hook *logHook) rotate() error {
+	hook.WriterLock.Lock()
+	defer hook.WriterLock.Unlock()
+	if hook.closed || hook.LogConfig.LogFileDisable {
+		return nil
+	}
+	fileSeq, logSize := hook.FileSeq, hook.LogSize.Load()
+	if hook.LogConfig.MaxLogSize > 0 {
+		if hook.LogConfig.DateSplit {
+			hook.FileSeq++
+		} else {
+			hook.newSegment = true
+		}
+	}
+	closedFiles, err := hook.switchFiles()
+	hook.newSegment = false
+	if err != nil {
+		hook.FileSeq = fileSeq
+		hook.LogSize.Store(logSize)
+		return err
+	}
+	hook.afterSplit(closedFiles)
+	return nil
+}
+
+func (hook *logHook) reopen() error {
+	hook.WriterLock.Lock()
+	defer hook.WriterLock.Unlock()
+	if hook.closed || hook.LogConfig.LogFileDisable {
+		return nil
+	}
+	return hook.reopenFiles()
+}
+
+// 必须加锁调用，关闭并重新打开当前路径的文件。
+// 不经过updateNewLogPathAndFile，仅按大小分割时它可能选择另一个文件。
+func (hook *logHook) reopenFiles() error {
+	// 队列中的日志属于旧文件
+	hook.drainQueue()
+	if hook.OtherBufWriter != nil {
+		hook.OtherBufWriter.Flush()
+	}
+	var size int64
+	if hook.OtherWriter != nil {
+		path := hook.OtherWriter.Name()
+		// 文件夹可能也被删除了(分离错误日志时的日期文件夹)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			return err
+		}
+		hook.OtherWriter.Close()
+		hook.OtherWriter = file
+		if !hook.LogConfig.DisableWriterBuffer {
+			hook.OtherBufWriter = bufio.NewWriterSize(file, hook.WriterBufferSize)
+		}
+		size, _ = file.Seek(0, io.SeekEnd)
+	}
+	// 错误日志和路由的文件在下次写入时创建
+	reopenLazy := func(w *doraemon.LazyFileWriter) *doraemon.LazyFileWriter {
+		if w == nil {
+			return nil
+		}
+		w.Close()
+		if info, err := os.Stat(w.Path()); err == nil {
+			size += info.Size()
+		}
+		return doraemon.NewLazyFileWriter(w.Path())
+	}
+	hook.ErrWriter = reopenLazy(hook.ErrWriter)
+	var routeWriters []*doraemon.LazyFileWriter
+	for _, w := range hook.RouteWriters {
+		routeWriters = append(routeWriters, reopenLazy(w))
+	}
+	hook.RouteWriters = routeWriters
+	hook.LogSize.Store(size)
+	hook.updateCurrentLinks()
+	return nil
+}
+
+// 每隔ReopenCheckInterval检查当前的日志文件是否被移动或删除
+func (hook *logHook) movedFileChecker() {
+	defer hook.wg.Done()
+	timer := time.NewTimer(time.Second)
+	defer timer.Stop()
+	for {
+		hook.WriterLock.RLock()
+		interval := hook.LogConfig.ReopenCheckInterval
+		hook.WriterLock.RUnlock()
+		enabled := interval > 0
+		if !enabled {
+			// 可能通过UpdateConfig开启
+			interval = time.Second
+		}
+		timer.Reset(interval)
+		select {
+		case <-hook.ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if enabled {
+			hook.reopenIfMoved()
+		}
+	}
+}
+
+func (hook *logHook) reopenIfMoved() {
+	hook.WriterLock.Lock()
+	defer hook.WriterLock.Unlock()
+	if hook.closed || hook.LogConfig.LogFileDisable {
+		return
+	}
+	moved := hook.OtherWriter != nil && fileMoved(hook.OtherWriter)
+	for _, w := range hook.lazyWriters() {
+		if !moved && w.IsCreated() {
+			moved = fileMoved(w.File())
+		}
+	}
+	if !moved {
+		return
+	}
+	if err := hook.reopenFiles(); err != nil {
+		fmt.Fprintln(os.Stderr, "mylog: reopen err:", err)
+	}
+}
+
+// 文件路径对应的文件(设备号和inode)是否已不是打开的文件
+func fileMoved(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	pathInfo, err := os.Stat(f.Name())
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return !os.SameFile(info, pathInfo)
+}
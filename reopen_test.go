@@ -0,0 +1,189 @@
+package mylog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{LogDir: dir, NoConsole: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	path := filepath.Join(dir, "default.log")
+
+	logger.Info("before rotate")
+	// logrotate的create模式：移动文件后通知重新打开
+	if err := Sync(logger); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reopen(logger); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("after rotate")
+	if err := Sync(logger); err != nil {
+		t.Fatal(err)
+	}
+	if content := readLog(t, path+".1"); !strings.Contains(content, "before rotate") || strings.Contains(content, "after rotate") {
+		t.Errorf("moved file = %q", content)
+	}
+	if content := readLog(t, path); !strings.Contains(content, "after rotate") || strings.Contains(content, "before rotate") {
+		t.Errorf("reopened file = %q", content)
+	}
+}
+
+func TestReopenMovedFile(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		DisableWriterBuffer: true,
+		ReopenCheckInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	path := filepath.Join(dir, "default.log")
+
+	logger.Info("deleted")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("deleted log file was not reopened")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	logger.Info("recreated")
+	if content := readLog(t, path); !strings.Contains(content, "recreated") {
+		t.Errorf("reopened file = %q", content)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		DisableWriterBuffer: true,
+		DateSplit:           true,
+		MaxLogSize:          1 << 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+
+	logger.Info("first segment")
+	if err := Rotate(logger); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("second segment")
+	files, err := getFileNmaesInPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("files = %v", files)
+	}
+	today := time.Now().Format("2006_01_02")
+	if content := readLog(t, filepath.Join(dir, today+".1.log")); !strings.Contains(content, "second segment") {
+		t.Errorf("new segment = %q", content)
+	}
+}
+
+func TestRotateSizeOnly(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		DisableWriterBuffer: true,
+		MaxLogSize:          1 << 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+
+	logger.Info("first file")
+	// 文件名中的时间精确到秒，在下一秒的开始连续分割两次
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second + 10*time.Millisecond)))
+	base := time.Now().Format(sizeSplitTimeFmt)
+	if err := Rotate(logger); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("second file")
+	if err := Rotate(logger); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("third file")
+
+	files, err := getFileNmaesInPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("files = %v", files)
+	}
+	if content := readLog(t, filepath.Join(dir, base+".log")); !strings.Contains(content, "second file") || strings.Contains(content, "third file") {
+		t.Errorf("second file = %q", content)
+	}
+	if content := readLog(t, filepath.Join(dir, base+".1.log")); !strings.Contains(content, "third file") || strings.Contains(content, "second file") {
+		t.Errorf("third file = %q", content)
+	}
+}
+
+func TestReopenSizeOnly(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		DisableWriterBuffer: true,
+		MaxLogSize:          1 << 20,
+		Routes:              []Route{{Name: "warn", Levels: []string{"warn"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	hook := findLogHooks(logger)[0]
+	path := hook.OtherWriter.Name()
+	routePath := hook.RouteWriters[0].Path()
+
+	logger.Warn("before reopen")
+	// 较新的文件不应被Reopen选中
+	newer := hook.commonTmpl.render(time.Now().Add(time.Hour), 0) + hook.LogConfig.LogExt
+	if err := os.WriteFile(filepath.Join(dir, newer), nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path, path+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reopen(logger); err != nil {
+		t.Fatal(err)
+	}
+	logger.Warn("after reopen")
+	if hook.OtherWriter.Name() != path || hook.RouteWriters[0].Path() != routePath {
+		t.Errorf("reopened %s and %s, want %s and %s", hook.OtherWriter.Name(), hook.RouteWriters[0].Path(), path, routePath)
+	}
+	if content := readLog(t, path); !strings.Contains(content, "after reopen") || strings.Contains(content, "before reopen") {
+		t.Errorf("reopened file = %q", content)
+	}
+	if content := readLog(t, routePath); !strings.Contains(content, "before reopen") || !strings.Contains(content, "after reopen") {
+		t.Errorf("route file = %q", content)
+	}
+}
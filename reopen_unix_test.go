@@ -0,0 +1,46 @@
+//go:build unix
+
+package mylog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReopenOnSignal(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{LogDir: dir, NoConsole: true, DisableWriterBuffer: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	stop := ReopenOnSignal(logger, syscall.SIGUSR1)
+	defer stop()
+	path := filepath.Join(dir, "default.log")
+
+	logger.Info("before signal")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("log file was not reopened on signal")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	logger.Info("after signal")
+	if content := readLog(t, path); !strings.Contains(content, "after signal") {
+		t.Errorf("reopened file = %q", content)
+	}
+}
@@ -0,0 +1,352 @@
+package mylog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogSegment is a log file or a date folder in LogDir seen by the retention policies.
+type LogSegment struct {
+	// Absolute path of the file or folder.
+	Path string
+	// Size in bytes, for a folder it is the total size of the log files inside.
+	Size int64
+	// Creation time parsed from the name in LogConfig.TimeLocation,
+	// or the modification time if the name has no time (e.g. files matched by RetentionPatterns).
+	Time time.Time
+	// TimeFromName reports whether Time was parsed from the name.
+	TimeFromName bool
+	// IsDir reports whether the segment is a date folder. Deleting a folder deletes
+	// the log files inside and then the folder itself if it is empty.
+	IsDir bool
+	// Reason why the segment is deleted.
+	Reason string
+	// 同一周期内的分段序号
+	seq int
+}
+
+// 保留策略
+type retentionPolicy struct {
+	// 保留天数，0表示不按天数清理
+	keepDays int
+	// 删除所有日志(正在写入的和以KeepSuffix结尾的除外)
+	deleteAll    bool
+	maxTotalSize int64
+	maxBackups   int
+}
+
+func (hook *logHook) configRetentionPolicy() retentionPolicy {
+	return retentionPolicy{
+		keepDays:     hook.LogConfig.MaxKeepDays,
+		maxTotalSize: hook.LogConfig.MaxTotalSize,
+		maxBackups:   hook.LogConfig.MaxBackups,
+	}
+}
+
+func (hook *logHook) deleteOldLogTimer() {
+	defer hook.wg.Done()
+	hook.applyRetention()
+
+	ticker := time.NewTicker(time.Hour * 24)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-hook.ctx.Done():
+			return
+		case <-ticker.C:
+			hook.applyRetention()
+		}
+	}
+}
+
+// 按配置的保留天数、总大小和数量清理日志
+func (hook *logHook) applyRetention() {
+	hook.splitMu.Lock()
+	defer hook.splitMu.Unlock()
+	policy := hook.configRetentionPolicy()
+	if policy == (retentionPolicy{}) {
+		return
+	}
+	plan, err := hook.planRetention(policy)
+	if err != nil {
+		logrus.Errorf("deleteOldLog plan err:%v", err)
+		return
+	}
+	hook.deleteSegments(plan)
+}
+
+// 删除过期日志(n<=0时删除所有)
+func (hook *logHook) deleteOldLogOnce(n int) {
+	hook.WriterLock.RLock()
+	logDir := hook.LogConfig.LogDir
+	hook.WriterLock.RUnlock()
+	if logDir == "" {
+		// 仅支持删除文件夹中的日志
+		return
+	}
+	if n <= 0 {
+		// return
+		hook.WriterLock.Lock()
+		if hook.closed {
+			hook.WriterLock.Unlock()
+			return
+		}
+		if hook.OtherBufWriter != nil {
+			hook.OtherBufWriter.Flush()
+		}
+		for _, w := range hook.lazyWriters() {
+			if !w.IsCreated() {
+				continue
+			}
+			path := w.Path()
+			w.Close()
+			err := os.Remove(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "deleteOldLog os.Remove err:%v", err)
+			}
+		}
+		if hook.OtherWriter != nil {
+			path := hook.OtherWriter.Name()
+			hook.OtherWriter.Close()
+			err := os.Remove(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "deleteOldLog os.Remove err:%v", err)
+			}
+		}
+		hook.updateNewLogPathAndFile()
+		hook.WriterLock.Unlock()
+	}
+	hook.splitMu.Lock()
+	defer hook.splitMu.Unlock()
+	plan, err := hook.planRetention(retentionPolicy{keepDays: n, deleteAll: n <= 0})
+	if err != nil {
+		logrus.Errorf("deleteOldLog plan err:%v", err)
+		return
+	}
+	hook.deleteSegments(plan)
+}
+
+// 返回正在写入的日志文件的绝对路径
+func (hook *logHook) activeLogFiles() map[string]bool {
+	hook.WriterLock.RLock()
+	defer hook.WriterLock.RUnlock()
+	var active = make(map[string]bool)
+	if hook.OtherWriter != nil {
+		path, _ := filepath.Abs(hook.OtherWriter.Name())
+		active[path] = true
+	}
+	for _, w := range hook.lazyWriters() {
+		path, _ := filepath.Abs(w.Path())
+		active[path] = true
+	}
+	return active
+}
+
+// 判断文件是否由本库创建，并从文件名中解析创建时间和分段序号(支持压缩后的文件名)。
+// 文件名需匹配文件名模板和LogExt，或匹配RetentionPatterns(此时使用最后修改时间)。
+func (hook *logHook) parseSegmentName(name string) (t time.Time, seq int, fromName bool, owned bool) {
+	for _, pattern := range hook.LogConfig.RetentionPatterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			owned = true
+			break
+		}
+	}
+	base := strings.TrimSuffix(name, compressSuffix)
+	if !strings.HasSuffix(base, hook.LogConfig.LogExt) {
+		return time.Time{}, 0, false, owned
+	}
+	base = strings.TrimSuffix(base, hook.LogConfig.LogExt)
+	tmpls := []*fileNameTemplate{hook.commonTmpl, hook.errorTmpl}
+	for _, route := range hook.routes {
+		tmpls = append(tmpls, route.tmpl)
+	}
+	for _, tmpl := range tmpls {
+		if t, seq, ok := tmpl.parse(base, hook.LogConfig.TimeLocation); ok {
+			return t, seq, tmpl.hasTime, true
+		}
+	}
+	return time.Time{}, 0, false, owned
+}
+
+// 从日期文件夹名中解析日期，如 2006_01_02
+func (hook *logHook) parseFolderName(dir string) (time.Time, bool) {
+	t, err := time.ParseInLocation(hook.dateFmt, dir, hook.LogConfig.TimeLocation)
+	if err != nil || t.Format(hook.dateFmt) != dir {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// 扫描dir中由本库创建的日志文件，跳过以keep结尾的文件，正在写入的文件只统计大小。
+func (hook *logHook) scanLogFiles(dir string, active map[string]bool) ([]LogSegment, int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	var segments []LogSegment
+	var activeSize int64
+	for _, entry := range entries {
+		// 跳过CurrentLink等符号链接
+		if entry.IsDir() || entry.Type()&os.ModeSymlink != 0 || hook.hasKeepSuffix(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path, _ := filepath.Abs(filepath.Join(dir, entry.Name()))
+		if active[path] {
+			activeSize += info.Size()
+			continue
+		}
+		t, seq, fromName, owned := hook.parseSegmentName(entry.Name())
+		if !owned {
+			continue
+		}
+		seg := LogSegment{Path: path, Size: info.Size(), Time: info.ModTime(), seq: seq}
+		if fromName {
+			seg.Time, seg.TimeFromName = t, true
+		}
+		segments = append(segments, seg)
+	}
+	return segments, activeSize, nil
+}
+
+// 根据保留策略返回需要删除的日志，不会删除任何文件。
+// 先按天数选出过期的日期文件夹和文件，再对剩余的文件按总大小和数量从最旧的开始选出。
+func (hook *logHook) planRetention(policy retentionPolicy) ([]LogSegment, error) {
+	if hook.LogConfig.LogDir == "" {
+		// 仅支持删除文件夹中的日志
+		return nil, nil
+	}
+	active := hook.activeLogFiles()
+	now := time.Now()
+	expired := func(t time.Time) (string, bool) {
+		if policy.deleteAll {
+			return "delete all logs", true
+		}
+		if policy.keepDays > 0 && now.Sub(t) > time.Duration(policy.keepDays)*24*time.Hour {
+			return fmt.Sprintf("older than %d days", policy.keepDays), true
+		}
+		return "", false
+	}
+
+	var plan []LogSegment
+	var remaining []LogSegment
+	var total int64
+	addFiles := func(files []LogSegment) {
+		for _, seg := range files {
+			if reason, ok := expired(seg.Time); ok {
+				seg.Reason = reason
+				plan = append(plan, seg)
+				continue
+			}
+			total += seg.Size
+			remaining = append(remaining, seg)
+		}
+	}
+
+	files, activeSize, err := hook.scanLogFiles(hook.LogConfig.LogDir, active)
+	if err != nil {
+		return nil, err
+	}
+	total += activeSize
+	addFiles(files)
+
+	dirs, err := getFolderNamesInPath(hook.LogConfig.LogDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if strings.HasSuffix(dir, hook.LogConfig.KeepSuffix) {
+			continue
+		}
+		dirTime, ok := hook.parseFolderName(dir)
+		if !ok {
+			continue
+		}
+		dirPath, _ := filepath.Abs(filepath.Join(hook.LogConfig.LogDir, dir))
+		files, activeSize, err := hook.scanLogFiles(dirPath, active)
+		if err != nil {
+			return nil, err
+		}
+		total += activeSize
+		if reason, ok := expired(dirTime); ok {
+			seg := LogSegment{Path: dirPath, Time: dirTime, TimeFromName: true, IsDir: true, Reason: reason}
+			for _, file := range files {
+				seg.Size += file.Size
+			}
+			plan = append(plan, seg)
+			continue
+		}
+		addFiles(files)
+	}
+
+	if policy.maxTotalSize <= 0 && policy.maxBackups <= 0 {
+		return plan, nil
+	}
+	sort.SliceStable(remaining, func(i, j int) bool {
+		if !remaining[i].Time.Equal(remaining[j].Time) {
+			return remaining[i].Time.Before(remaining[j].Time)
+		}
+		if remaining[i].seq != remaining[j].seq {
+			return remaining[i].seq < remaining[j].seq
+		}
+		return remaining[i].Path < remaining[j].Path
+	})
+	for len(remaining) > 0 {
+		seg := remaining[0]
+		switch {
+		case policy.maxBackups > 0 && len(remaining) > policy.maxBackups:
+			seg.Reason = fmt.Sprintf("exceeds MaxBackups %d", policy.maxBackups)
+		case policy.maxTotalSize > 0 && total > policy.maxTotalSize:
+			seg.Reason = fmt.Sprintf("exceeds MaxTotalSize %d bytes", policy.maxTotalSize)
+		default:
+			return plan, nil
+		}
+		plan = append(plan, seg)
+		remaining = remaining[1:]
+		total -= seg.Size
+	}
+	return plan, nil
+}
+
+// 删除planRetention返回的日志，删除后为空的日期文件夹也会被删除。
+// 由于调用了logrus.Errorf，所以不要对此方法加WriterLock，否则会死锁。
+func (hook *logHook) deleteSegments(plan []LogSegment) {
+	logDir, _ := filepath.Abs(hook.LogConfig.LogDir)
+	var touchedDirs = make(map[string]bool)
+	for _, seg := range plan {
+		if !seg.IsDir {
+			if err := os.Remove(seg.Path); err != nil {
+				logrus.Errorf("deleteOldLog os.Remove err:%v", err)
+			}
+			touchedDirs[filepath.Dir(seg.Path)] = true
+			continue
+		}
+		files, _, err := hook.scanLogFiles(seg.Path, hook.activeLogFiles())
+		if err != nil {
+			logrus.Errorf("deleteOldLog scan dir err:%v", err)
+			continue
+		}
+		for _, file := range files {
+			if err := os.Remove(file.Path); err != nil {
+				logrus.Errorf("deleteOldLog os.Remove err:%v", err)
+			}
+		}
+		touchedDirs[seg.Path] = true
+	}
+	for dir := range touchedDirs {
+		if dir != logDir && isEmptyDir(dir) {
+			if err := os.Remove(dir); err != nil {
+				logrus.Errorf("deleteOldLogDir os.Remove err:%v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package mylog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeleteExcessLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		config LogConfig
+		check  func(t *testing.T, files []string, total int64)
+	}{
+		{"MaxBackups", LogConfig{MaxBackups: 2}, func(t *testing.T, files []string, _ int64) {
+			// 2 backups and the active file, plus the keep file
+			if len(files) != 4 {
+				t.Errorf("files = %v", files)
+			}
+		}},
+		{"MaxTotalSize", LogConfig{MaxTotalSize: 2048}, func(t *testing.T, files []string, total int64) {
+			if total > 2048+1024 {
+				t.Errorf("total size = %d, files = %v", total, files)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			keep := filepath.Join(dir, "2000_01_01_keep.log")
+			if err := os.WriteFile(keep, make([]byte, 4096), 0666); err != nil {
+				t.Fatal(err)
+			}
+			config := tt.config
+			config.LogDir = dir
+			config.NoConsole = true
+			config.DateSplit = true
+			config.MaxLogSize = 512
+			config.DisableWriterBuffer = true
+			logger, err := NewLogger(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 100; i++ {
+				logger.Info("0123456789012345678901234567890123456789")
+			}
+			if err := Close(logger); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(keep); err != nil {
+				t.Errorf("keep file was deleted: %v", err)
+			}
+			files, _ := getFileNmaesInPath(dir)
+			var total int64
+			for _, file := range files {
+				if file == filepath.Base(keep) {
+					continue
+				}
+				info, _ := os.Stat(filepath.Join(dir, file))
+				total += info.Size()
+			}
+			tt.check(t, files, total)
+		})
+	}
+}
+
+func TestPlanRetention(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:       dir,
+		NoConsole:    true,
+		DateSplit:    true,
+		TimeLocation: time.UTC,
+		KeepSuffix:   "pinned",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	hook := findLogHooks(logger)[0]
+
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	today := time.Now().UTC().Format("2006_01_02")
+	write := func(name string, modTime time.Time) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("x"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+	write("2000_01_01.log", time.Now())          // touched by a backup tool
+	write("2000_01_01_error.log.gz", time.Now()) // compressed segment
+	write("2000_01_03_pinned.log", old)
+	write("2000_01_04_keep.log", old)
+	write("notes.txt", old)
+	write("app-legacy.log", old)
+	write("2000_01_06", old)
+	write(today+"_error.log", old)
+	write(filepath.Join("2000_01_02", "2000_01_02_error.log"), time.Now())
+
+	plan, err := hook.planRetention(retentionPolicy{keepDays: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]LogSegment)
+	for _, seg := range plan {
+		got[filepath.Base(seg.Path)] = seg
+	}
+	for _, name := range []string{"2000_01_01.log", "2000_01_01_error.log.gz", "2000_01_02"} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("%s is not planned for deletion, plan = %v", name, plan)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("plan = %v", plan)
+	}
+	if seg := got["2000_01_01.log"]; !seg.TimeFromName || seg.Reason != "older than 3 days" {
+		t.Errorf("segment = %+v", seg)
+	}
+	if seg := got["2000_01_02"]; !seg.IsDir || !seg.Time.Equal(time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("segment = %+v", seg)
+	}
+	// dry run deletes nothing
+	if _, err := os.Stat(filepath.Join(dir, "2000_01_01.log")); err != nil {
+		t.Error(err)
+	}
+
+	// files created by an older naming configuration
+	hook.LogConfig.RetentionPatterns = []string{"app-*.log"}
+	plan, err = hook.planRetention(retentionPolicy{keepDays: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, seg := range plan {
+		if filepath.Base(seg.Path) == "app-legacy.log" {
+			found = !seg.TimeFromName
+		}
+	}
+	if !found || len(plan) != 4 {
+		t.Errorf("plan = %v", plan)
+	}
+}
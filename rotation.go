@@ -0,0 +1,332 @@
+package mylog
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RotationPeriod is the period of log rotation when LogConfig.DateSplit is enabled.
+type RotationPeriod string
+
+const (
+	RotateDaily   RotationPeriod = "daily"
+	RotateHourly  RotationPeriod = "hourly"
+	RotateWeekly  RotationPeriod = "weekly"
+	RotateMonthly RotationPeriod = "monthly"
+	// Rotate every LogConfig.RotationMinutes minutes.
+	RotateMinutes RotationPeriod = "minutes"
+)
+
+// 各周期默认的时间格式(同时作为分离错误日志时的文件夹名)
+func (p RotationPeriod) layout() (string, error) {
+	switch p {
+	case "", RotateDaily, RotateWeekly:
+		return "2006_01_02", nil
+	case RotateHourly:
+		return "2006_01_02_15", nil
+	case RotateMinutes:
+		return "2006_01_02_1504", nil
+	case RotateMonthly:
+		return "2006_01", nil
+	default:
+		return "", fmt.Errorf("unknown rotation period: %q", p)
+	}
+}
+
+// 返回t所在周期的开始时间(t所在时区)
+func periodStart(t time.Time, p RotationPeriod, minutes int) time.Time {
+	y, m, d := t.Date()
+	switch p {
+	case RotateHourly:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	case RotateMinutes:
+		if minutes <= 0 {
+			minutes = 1
+		}
+		n := (t.Hour()*60 + t.Minute()) / minutes * minutes
+		return time.Date(y, m, d, n/60, n%60, 0, 0, t.Location())
+	case RotateWeekly:
+		// 以周一为一周的开始
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+	case RotateMonthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+}
+
+type templatePartKind int
+
+const (
+	partLiteral templatePartKind = iota
+	partTime
+	partPid
+	partSeq
+)
+
+type templatePart struct {
+	kind templatePartKind
+	// 字面量或时间格式
+	text string
+}
+
+// 日志文件名模板(不含扩展名)，支持的占位符:
+//
+//	{time} {time:2006-01-02} {name} {suffix} {hostname} {pid} {seq}
+type fileNameTemplate struct {
+	parts []templatePart
+	// 模板中没有{seq}时，序号大于0则在末尾追加 .N
+	implicitSeq bool
+	hasTime     bool
+	hasSeq      bool
+	// 用于从文件名中解析时间和序号
+	re *regexp.Regexp
+	// re中时间和序号的分组下标，-1表示没有
+	timeGroup        int
+	timeLayout       string
+	seqGroup         int
+	implicitSeqGroup int
+}
+
+// vars 为{name}、{suffix}、{hostname}等固定占位符的值
+func newFileNameTemplate(tmpl string, defaultLayout string, implicitSeq bool, vars map[string]string) (*fileNameTemplate, error) {
+	t := &fileNameTemplate{timeGroup: -1, seqGroup: -1, implicitSeqGroup: -1}
+	for len(tmpl) > 0 {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			t.parts = append(t.parts, templatePart{kind: partLiteral, text: tmpl})
+			break
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in file name template: %q", tmpl[start:])
+		}
+		end += start
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{kind: partLiteral, text: tmpl[:start]})
+		}
+		name := tmpl[start+1 : end]
+		switch {
+		case name == "time":
+			t.parts = append(t.parts, templatePart{kind: partTime, text: defaultLayout})
+			t.hasTime = true
+		case strings.HasPrefix(name, "time:"):
+			layout := name[len("time:"):]
+			if layout == "" || makeFileNameLegal(layout) != layout {
+				return nil, fmt.Errorf("invalid time layout in file name template: %q", layout)
+			}
+			t.parts = append(t.parts, templatePart{kind: partTime, text: layout})
+			t.hasTime = true
+		case name == "pid":
+			t.parts = append(t.parts, templatePart{kind: partPid})
+		case name == "seq":
+			t.parts = append(t.parts, templatePart{kind: partSeq})
+			t.hasSeq = true
+		default:
+			value, ok := vars[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown placeholder {%s} in file name template", name)
+			}
+			t.parts = append(t.parts, templatePart{kind: partLiteral, text: value})
+		}
+		tmpl = tmpl[end+1:]
+	}
+	t.implicitSeq = implicitSeq && !t.hasSeq
+
+	var expr strings.Builder
+	var group = 0
+	expr.WriteString("^")
+	for _, part := range t.parts {
+		switch part.kind {
+		case partLiteral:
+			expr.WriteString(regexp.QuoteMeta(makeFileNameLegal(part.text)))
+		case partTime:
+			group++
+			if t.timeGroup < 0 {
+				t.timeGroup = group
+				t.timeLayout = part.text
+			}
+			expr.WriteString("(" + timeLayoutPattern(part.text) + ")")
+		case partPid:
+			expr.WriteString(`\d+`)
+		case partSeq:
+			group++
+			if t.seqGroup < 0 {
+				t.seqGroup = group
+			}
+			expr.WriteString(`(\d+)`)
+		}
+	}
+	if t.implicitSeq {
+		group++
+		t.implicitSeqGroup = group
+		expr.WriteString(`(?:\.([1-9]\d*))?`)
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	t.re = re
+	return t, nil
+}
+
+// 时间格式输出长度固定时精确匹配长度，否则尽量少匹配
+func timeLayoutPattern(layout string) string {
+	a := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC).Format(layout)
+	b := time.Date(2019, 11, 30, 3, 14, 59, 0, time.UTC).Format(layout)
+	if len(a) == len(b) {
+		return ".{" + strconv.Itoa(len([]rune(a))) + "}"
+	}
+	return ".+?"
+}
+
+// 生成文件名(不含扩展名)
+func (t *fileNameTemplate) render(tm time.Time, seq int) string {
+	var buf strings.Builder
+	for _, part := range t.parts {
+		switch part.kind {
+		case partLiteral:
+			buf.WriteString(part.text)
+		case partTime:
+			buf.WriteString(tm.Format(part.text))
+		case partPid:
+			buf.WriteString(strconv.Itoa(os.Getpid()))
+		case partSeq:
+			buf.WriteString(strconv.Itoa(seq))
+		}
+	}
+	if t.implicitSeq && seq > 0 {
+		buf.WriteString("." + strconv.Itoa(seq))
+	}
+	return makeFileNameLegal(buf.String())
+}
+
+// 解析由模板生成的文件名(不含扩展名)，返回文件名中的时间(模板中没有时间时为零值)和序号。
+func (t *fileNameTemplate) parse(name string, loc *time.Location) (time.Time, int, bool) {
+	match := t.re.FindStringSubmatch(name)
+	if match == nil {
+		return time.Time{}, 0, false
+	}
+	var tm time.Time
+	if t.timeGroup > 0 {
+		var err error
+		tm, err = time.ParseInLocation(t.timeLayout, match[t.timeGroup], loc)
+		if err != nil {
+			return time.Time{}, 0, false
+		}
+	}
+	var seq int
+	for _, g := range []int{t.seqGroup, t.implicitSeqGroup} {
+		if g > 0 && match[g] != "" {
+			seq, _ = strconv.Atoi(match[g])
+		}
+	}
+	return tm, seq, true
+}
+
+// 根据配置初始化分割周期和文件名模板
+func (hook *logHook) initFileNameTemplates() error {
+	config := hook.LogConfig
+	hook.period = RotateDaily
+	if config.DateSplit && config.RotationPeriod != "" {
+		hook.period = config.RotationPeriod
+	}
+	if hook.period == RotateMinutes && config.RotationMinutes <= 0 {
+		return fmt.Errorf("RotationMinutes must be positive when RotationPeriod is %q", RotateMinutes)
+	}
+	layout, err := hook.period.layout()
+	if err != nil {
+		return err
+	}
+	hook.dateFmt = layout
+
+	timeLayout := hook.dateFmt2
+	if config.DateSplit {
+		timeLayout = hook.dateFmt
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	vars := map[string]string{
+		"name":     config.DefaultLogName,
+		"suffix":   config.LogFileNameSuffix,
+		"hostname": hostname,
+	}
+	// 仅按大小分割时，同一秒内创建的文件也通过序号区分
+	implicitSeq := config.MaxLogSize > 0
+
+	// 错误日志和路由的文件名在普通日志文件名的基础上加上后缀
+	var commonTmpl string
+	suffixed := func(suffix string) string {
+		return config.LogFileNameTemplate + "_" + suffix
+	}
+	if config.LogFileNameTemplate != "" {
+		commonTmpl = config.LogFileNameTemplate
+	} else {
+		var base = "{name}"
+		if config.DateSplit || config.MaxLogSize > 0 {
+			base = "{time}"
+		}
+		commonTmpl = base
+		suffixed = func(suffix string) string {
+			if config.LogFileNameSuffix != "" {
+				return base + "_" + suffix + "_{suffix}"
+			}
+			return base + "_" + suffix
+		}
+		if config.LogFileNameSuffix != "" {
+			commonTmpl += "_{suffix}"
+		}
+	}
+	hook.commonTmpl, err = newFileNameTemplate(commonTmpl, timeLayout, implicitSeq, vars)
+	if err != nil {
+		return err
+	}
+	hook.errorTmpl, err = newFileNameTemplate(suffixed("error"), timeLayout, implicitSeq, vars)
+	if err != nil {
+		return err
+	}
+	hook.routes = nil
+	for _, r := range config.Routes {
+		route := newLogRoute(r)
+		if route.tmpl, err = newFileNameTemplate(suffixed(r.Name), timeLayout, implicitSeq, vars); err != nil {
+			return err
+		}
+		hook.routes = append(hook.routes, route)
+	}
+	if (config.DateSplit || config.MaxLogSize > 0) && !hook.commonTmpl.hasTime {
+		return fmt.Errorf("LogFileNameTemplate must contain {time} when splitting logs")
+	}
+	return nil
+}
+
+// 返回t所在周期的名称，如 2006_01_02
+func (hook *logHook) periodKey(t time.Time) string {
+	return hook.periodStartOf(t).Format(hook.dateFmt)
+}
+
+func (hook *logHook) periodStartOf(t time.Time) time.Time {
+	return periodStart(t.In(hook.LogConfig.TimeLocation), hook.period, hook.LogConfig.RotationMinutes)
+}
+
+// 返回序号为seq的普通日志和错误日志文件名(含扩展名)
+func (hook *logHook) logFileNames(seq int) (commonName, errorName string) {
+	commonName = hook.commonTmpl.render(hook.fileTime, seq) + hook.LogConfig.LogExt
+	errorName = hook.errorTmpl.render(hook.fileTime, seq) + hook.LogConfig.LogExt
+	return commonName, errorName
+}
+
+// 解析普通日志文件名中的时间和序号
+func (hook *logHook) parseLogFileName(fileName string) (time.Time, int, bool) {
+	if !strings.HasSuffix(fileName, hook.LogConfig.LogExt) {
+		return time.Time{}, 0, false
+	}
+	return hook.commonTmpl.parse(strings.TrimSuffix(fileName, hook.LogConfig.LogExt), hook.LogConfig.TimeLocation)
+}
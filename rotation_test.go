@@ -0,0 +1,75 @@
+package mylog
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_periodStart(t *testing.T) {
+	tm := time.Date(2024, 5, 16, 13, 47, 21, 0, time.UTC) // Thursday
+	tests := []struct {
+		period  RotationPeriod
+		minutes int
+		want    time.Time
+	}{
+		{RotateDaily, 0, time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{RotateHourly, 0, time.Date(2024, 5, 16, 13, 0, 0, 0, time.UTC)},
+		{RotateMinutes, 15, time.Date(2024, 5, 16, 13, 45, 0, 0, time.UTC)},
+		{RotateMinutes, 7, time.Date(2024, 5, 16, 13, 46, 0, 0, time.UTC)},
+		{RotateWeekly, 0, time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{RotateMonthly, 0, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := periodStart(tm, tt.period, tt.minutes); !got.Equal(tt.want) {
+			t.Errorf("periodStart(%s, %d) = %v, want %v", tt.period, tt.minutes, got, tt.want)
+		}
+	}
+}
+
+func Test_fileNameTemplate(t *testing.T) {
+	vars := map[string]string{"name": "app", "suffix": "x", "hostname": "host-1"}
+	tm := time.Date(2024, 5, 16, 13, 0, 0, 0, time.UTC)
+	tests := []struct {
+		tmpl        string
+		implicitSeq bool
+		seq         int
+		want        string
+	}{
+		{"{time}", false, 0, "2024_05_16_13"},
+		{"{time}", true, 0, "2024_05_16_13"},
+		{"{time}", true, 2, "2024_05_16_13.2"},
+		{"{time}_error_{suffix}", true, 3, "2024_05_16_13_error_x.3"},
+		{"{name}-{hostname}-{time:20060102}", false, 0, "app-host-1-20240516"},
+		{"{name}-{time}-{seq}", true, 4, "app-2024_05_16_13-4"},
+	}
+	for _, tt := range tests {
+		tmpl, err := newFileNameTemplate(tt.tmpl, "2006_01_02_15", tt.implicitSeq, vars)
+		if err != nil {
+			t.Fatalf("newFileNameTemplate(%q) err = %v", tt.tmpl, err)
+		}
+		got := tmpl.render(tm, tt.seq)
+		if got != tt.want {
+			t.Errorf("render(%q) = %q, want %q", tt.tmpl, got, tt.want)
+			continue
+		}
+		parsed, seq, ok := tmpl.parse(got, time.UTC)
+		if !ok || seq != tt.seq {
+			t.Errorf("parse(%q) = %v, %d, %v", got, parsed, seq, ok)
+		}
+		if tmpl.hasTime && !parsed.Equal(tm.Truncate(24*time.Hour)) && !parsed.Equal(tm) {
+			t.Errorf("parse(%q) time = %v", got, parsed)
+		}
+	}
+
+	tmpl, _ := newFileNameTemplate("{time}", "2006_01_02", true, vars)
+	for _, name := range []string{"2024_05_16_error", "2024_05_16.01", "default", "2024_13_16"} {
+		if _, _, ok := tmpl.parse(name, time.UTC); ok {
+			t.Errorf("parse(%q) should fail", name)
+		}
+	}
+	for _, bad := range []string{"{unknown}", "{time", "{time:15:04}"} {
+		if _, err := newFileNameTemplate(bad, "2006_01_02", false, vars); err == nil {
+			t.Errorf("newFileNameTemplate(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package mylog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/doraemonkeys/doraemon"
+	"github.com/sirupsen/logrus"
+)
+
+// Route writes the entries of the listed levels to an extra log file. The file is split,
+// compressed and cleaned up together with the normal log file.
+type Route struct {
+	// Name of the route, the file is named like the normal log file with an _<Name> suffix
+	// (like the _error suffix of ErrSeparate), e.g. default_warn.log or 2006_01_02_warn.log, in the same folder.
+	Name string
+	// Levels written to the file (panic, fatal, error, warn, info, debug, trace).
+	Levels []string
+	// Remove the entries from the normal log file like ErrNotInNormal, otherwise they are copied.
+	Exclusive bool
+}
+
+// UnmarshalText parses a route in the form name:level|level[:exclusive], e.g. warn:warn|error,
+// it is used by ConfigFromEnv.
+func (r *Route) UnmarshalText(text []byte) error {
+	parts := strings.Split(string(text), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("invalid route %q, want name:level|level[:exclusive]", text)
+	}
+	r.Name = strings.TrimSpace(parts[0])
+	r.Levels = nil
+	for _, level := range strings.Split(parts[1], "|") {
+		r.Levels = append(r.Levels, strings.TrimSpace(level))
+	}
+	r.Exclusive = false
+	if len(parts) == 3 {
+		if strings.TrimSpace(parts[2]) != "exclusive" {
+			return fmt.Errorf("invalid route %q, want name:level|level[:exclusive]", text)
+		}
+		r.Exclusive = true
+	}
+	return nil
+}
+
+func validateRoutes(routes []Route, errSeparate bool) error {
+	var errs []error
+	names := make(map[string]bool)
+	for _, route := range routes {
+		switch {
+		case route.Name == "":
+			errs = append(errs, errors.New("the name of a route must not be empty"))
+		case strings.ContainsAny(route.Name, `/\{}`):
+			errs = append(errs, fmt.Errorf("route name must not contain path separators or braces: %q", route.Name))
+		case names[route.Name] || (errSeparate && route.Name == "error"):
+			errs = append(errs, fmt.Errorf("duplicate route %q", route.Name))
+		}
+		names[route.Name] = true
+		if len(route.Levels) == 0 {
+			errs = append(errs, fmt.Errorf("route %q: no levels", route.Name))
+		}
+		for _, level := range route.Levels {
+			if _, err := parseLevelStrict(level); err != nil {
+				errs = append(errs, fmt.Errorf("route %q: %w", route.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 由Route生成，文件名模板在initFileNameTemplates中生成
+type logRoute struct {
+	name string
+	// 按级别的位掩码
+	levels    uint32
+	exclusive bool
+	tmpl      *fileNameTemplate
+}
+
+func (r logRoute) match(level logrus.Level) bool {
+	return r.levels&(1<<level) != 0
+}
+
+func newLogRoute(route Route) logRoute {
+	r := logRoute{name: route.Name, exclusive: route.Exclusive}
+	for _, level := range route.Levels {
+		r.levels |= 1 << PraseLevel(level)
+	}
+	return r
+}
+
+// 在普通日志文件所在的文件夹中为各路由创建文件(第一次写入时才创建)，已有的文件计入日志大小
+func (hook *logHook) openRouteFiles() {
+	hook.RouteWriters = nil
+	if hook.OtherWriter == nil {
+		return
+	}
+	dir := filepath.Dir(hook.OtherWriter.Name())
+	for _, route := range hook.routes {
+		path := filepath.Join(dir, route.tmpl.render(hook.fileTime, hook.FileSeq)+hook.LogConfig.LogExt)
+		if info, err := os.Stat(path); err == nil {
+			hook.LogSize.Add(info.Size())
+		}
+		hook.RouteWriters = append(hook.RouteWriters, doraemon.NewLazyFileWriter(path))
+	}
+}
+
+// 关闭文件，返回追加了已创建的文件的closedFiles
+func closeLazyWriters(closedFiles []string, writers ...*doraemon.LazyFileWriter) []string {
+	for _, w := range writers {
+		if w == nil {
+			continue
+		}
+		if w.IsCreated() {
+			closedFiles = append(closedFiles, w.Path())
+		}
+		w.Close()
+	}
+	return closedFiles
+}
+
+// 错误日志和各路由的文件
+func (hook *logHook) lazyWriters() []*doraemon.LazyFileWriter {
+	var writers []*doraemon.LazyFileWriter
+	if hook.ErrWriter != nil {
+		writers = append(writers, hook.ErrWriter)
+	}
+	return append(writers, hook.RouteWriters...)
+}
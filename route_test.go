@@ -0,0 +1,163 @@
+package mylog
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoute_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Route
+		wantErr bool
+	}{
+		{"warn:warn", Route{Name: "warn", Levels: []string{"warn"}}, false},
+		{"debug:debug|trace:exclusive", Route{Name: "debug", Levels: []string{"debug", "trace"}, Exclusive: true}, false},
+		{"warn", Route{}, true},
+		{"warn:warn:copy", Route{}, true},
+	}
+	for _, tt := range tests {
+		var got Route
+		err := got.UnmarshalText([]byte(tt.text))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalText(%q) err = %v", tt.text, err)
+			continue
+		}
+		if !tt.wantErr && (got.Name != tt.want.Name || got.Exclusive != tt.want.Exclusive || !slices.Equal(got.Levels, tt.want.Levels)) {
+			t.Errorf("UnmarshalText(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+
+	if err := validateRoutes([]Route{{Name: "a", Levels: []string{"warn"}}, {Name: "a", Levels: []string{"loud"}}}, false); err == nil ||
+		!strings.Contains(err.Error(), "duplicate") || !strings.Contains(err.Error(), "unknown log level") {
+		t.Errorf("validateRoutes err = %v", err)
+	}
+	if err := validateRoutes([]Route{{Name: "error", Levels: []string{"warn"}}}, true); err == nil {
+		t.Error("route named error should conflict with ErrSeparate")
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		DisableWriterBuffer: true,
+		LogLevel:            "trace",
+		DateSplit:           true,
+		MaxLogSize:          1 << 20,
+		ErrSeparate:         true,
+		Routes: []Route{
+			{Name: "warn", Levels: []string{"warn", "error"}},
+			{Name: "debug", Levels: []string{"debug", "trace"}, Exclusive: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Trace("trace entry")
+	logger.Debug("debug entry")
+	logger.Info("info entry")
+	logger.Warn("warn entry")
+	logger.Error("error entry")
+	if err := Rotate(logger); err != nil {
+		t.Fatal(err)
+	}
+	logger.Warn("next segment")
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	today := time.Now().Format("2006_01_02")
+	folder := filepath.Join(dir, today)
+	tests := []struct {
+		file    string
+		want    []string
+		notWant []string
+	}{
+		{today + ".log", []string{"info entry", "warn entry", "error entry"}, []string{"trace entry", "debug entry"}},
+		{today + "_error.log", []string{"error entry"}, []string{"warn entry"}},
+		{today + "_warn.log", []string{"warn entry", "error entry"}, []string{"info entry", "next segment"}},
+		{today + "_debug.log", []string{"trace entry", "debug entry"}, []string{"info entry"}},
+		{today + "_warn.1.log", []string{"next segment"}, nil},
+	}
+	for _, tt := range tests {
+		content := readLog(t, filepath.Join(folder, tt.file))
+		for _, want := range tt.want {
+			if !strings.Contains(content, want) {
+				t.Errorf("%s does not contain %q", tt.file, want)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(content, notWant) {
+				t.Errorf("%s contains %q", tt.file, notWant)
+			}
+		}
+	}
+}
+
+func TestRouteRetention(t *testing.T) {
+	dir := t.TempDir()
+	hook := &logHook{LogConfig: LogConfig{
+		LogDir:    dir,
+		LogExt:    ".log",
+		DateSplit: true,
+		Routes:    []Route{{Name: "warn", Levels: []string{"warn"}}},
+	}, dateFmt2: sizeSplitTimeFmt}
+	hook.LogConfig.TimeLocation = time.Local
+	if err := hook.initFileNameTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"2024_05_16_warn.log", "2024_05_16_warn.log.gz"} {
+		if _, _, fromName, owned := hook.parseSegmentName(name); !owned || !fromName {
+			t.Errorf("%s should be owned by the logger", name)
+		}
+	}
+	if _, _, _, owned := hook.parseSegmentName("2024_05_16_info.log"); owned {
+		t.Error("files of unknown routes should not be owned")
+	}
+}
+
+func TestRoutesResume(t *testing.T) {
+	dir := t.TempDir()
+	config := LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		DisableWriterBuffer: true,
+		MaxLogSize:          1 << 20,
+		Routes:              []Route{{Name: "warn", Levels: []string{"warn"}}},
+	}
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Warn("first run")
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启时续写最新的文件，路由的文件与之对应
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second + 10*time.Millisecond)))
+	logger, err = NewLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Warn("second run")
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+	files, err := getFileNmaesInPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || !strings.HasSuffix(files[1], "_warn.log") ||
+		strings.TrimSuffix(files[1], "_warn.log") != strings.TrimSuffix(files[0], ".log") {
+		t.Fatalf("files = %v", files)
+	}
+	if content := readLog(t, filepath.Join(dir, files[1])); !strings.Contains(content, "first run") || !strings.Contains(content, "second run") {
+		t.Errorf("route file = %q", content)
+	}
+}
@@ -0,0 +1,204 @@
+package mylog
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 被采样或限流丢弃的entry在context中的标记，控制台输出时据此过滤。
+// 不写入entry.Data，其他hook和formatter不会看到
+type droppedContextKey struct{}
+
+// 标记entry已被丢弃
+func markDropped(entry *logrus.Entry) {
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	entry.Context = context.WithValue(ctx, droppedContextKey{}, true)
+}
+
+func isDropped(entry *logrus.Entry) bool {
+	return entry.Context != nil && entry.Context.Value(droppedContextKey{}) != nil
+}
+
+// 丢弃统计日志的context，不参与采样
+type summaryContextKey struct{}
+
+// 调用位置，没有调用者信息时同一级别共用一个
+type siteKey struct {
+	pc    uintptr
+	level logrus.Level
+}
+
+type siteState struct {
+	mu   sync.Mutex
+	site string
+	// 采样窗口
+	windowStart time.Time
+	count       int
+	// 令牌桶
+	tokens float64
+	last   time.Time
+	// 上次统计后丢弃的数量
+	dropped int64
+}
+
+// 按调用位置采样和限流
+type sampler struct {
+	initial    int
+	thereafter int
+	interval   time.Duration
+	rate       float64
+	burst      float64
+	// 丢弃统计的间隔
+	summaryInterval time.Duration
+	sites           sync.Map // siteKey -> *siteState
+}
+
+func newSampler(config LogConfig) *sampler {
+	if config.SampleInitial <= 0 && config.RateLimit <= 0 {
+		return nil
+	}
+	s := &sampler{
+		initial:         config.SampleInitial,
+		thereafter:      config.SampleThereafter,
+		interval:        config.SampleInterval,
+		rate:            config.RateLimit,
+		burst:           float64(config.RateBurst),
+		summaryInterval: config.DropSummaryInterval,
+	}
+	if s.interval <= 0 {
+		s.interval = time.Second
+	}
+	if s.burst <= 0 {
+		s.burst = math.Max(1, math.Ceil(s.rate))
+	}
+	if s.summaryInterval <= 0 {
+		s.summaryInterval = time.Minute
+	}
+	return s
+}
+
+func samplingChanged(old, config LogConfig) bool {
+	return old.SampleInitial != config.SampleInitial ||
+		old.SampleThereafter != config.SampleThereafter ||
+		old.SampleInterval != config.SampleInterval ||
+		old.RateLimit != config.RateLimit ||
+		old.RateBurst != config.RateBurst ||
+		old.DropSummaryInterval != config.DropSummaryInterval
+}
+
+// 判断entry是否应该记录，fatal和panic总是记录
+func (s *sampler) allow(entry *logrus.Entry, now time.Time) bool {
+	if entry.Level <= logrus.FatalLevel {
+		return true
+	}
+	if entry.Context != nil && entry.Context.Value(summaryContextKey{}) != nil {
+		return true
+	}
+	key := siteKey{level: entry.Level}
+	if entry.Caller != nil {
+		key.pc = entry.Caller.PC
+	}
+	v, ok := s.sites.Load(key)
+	if !ok {
+		site := "unknown"
+		if entry.Caller != nil {
+			site = fmt.Sprintf("%s:%d", filepath.Base(entry.Caller.File), entry.Caller.Line)
+		}
+		v, _ = s.sites.LoadOrStore(key, &siteState{site: site, windowStart: now, tokens: s.burst, last: now})
+	}
+	st := v.(*siteState)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	allowed := true
+	if s.initial > 0 {
+		if now.Sub(st.windowStart) >= s.interval {
+			st.windowStart, st.count = now, 0
+		}
+		st.count++
+		if st.count > s.initial && (s.thereafter <= 0 || (st.count-s.initial)%s.thereafter != 0) {
+			allowed = false
+		}
+	}
+	if allowed && s.rate > 0 {
+		st.tokens = math.Min(s.burst, st.tokens+now.Sub(st.last).Seconds()*s.rate)
+		st.last = now
+		if st.tokens >= 1 {
+			st.tokens--
+		} else {
+			allowed = false
+		}
+	}
+	if !allowed {
+		st.dropped++
+	}
+	return allowed
+}
+
+type dropSummary struct {
+	site    string
+	level   logrus.Level
+	dropped int64
+}
+
+// 返回并清零各调用位置丢弃的数量
+func (s *sampler) takeDropped() []dropSummary {
+	var summaries []dropSummary
+	s.sites.Range(func(k, v interface{}) bool {
+		st := v.(*siteState)
+		st.mu.Lock()
+		if st.dropped > 0 {
+			summaries = append(summaries, dropSummary{site: st.site, level: k.(siteKey).level, dropped: st.dropped})
+			st.dropped = 0
+		}
+		st.mu.Unlock()
+		return true
+	})
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].level != summaries[j].level {
+			return summaries[i].level < summaries[j].level
+		}
+		return summaries[i].site < summaries[j].site
+	})
+	return summaries
+}
+
+// 定期以被丢弃日志的级别记录各调用位置丢弃的数量
+func (hook *logHook) dropSummaryTimer() {
+	defer hook.wg.Done()
+	timer := time.NewTimer(time.Minute)
+	defer timer.Stop()
+	for {
+		interval := time.Minute
+		if s := hook.sampler.Load(); s != nil {
+			interval = s.summaryInterval
+		}
+		timer.Reset(interval)
+		select {
+		case <-hook.ctx.Done():
+			return
+		case <-timer.C:
+		}
+		s := hook.sampler.Load()
+		if s == nil {
+			continue
+		}
+		ctx := context.WithValue(context.Background(), summaryContextKey{}, true)
+		for _, summary := range s.takeDropped() {
+			hook.logger.WithContext(ctx).WithFields(logrus.Fields{
+				"site":    summary.site,
+				"dropped": summary.dropped,
+			}).Logf(summary.level, "%d log entries dropped by sampling and rate limiting in the last %v", summary.dropped, interval)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package mylog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func Test_sampler(t *testing.T) {
+	now := time.Now()
+	caller := &runtime.Frame{PC: 1, File: "/src/app/main.go", Line: 12}
+	entry := &logrus.Entry{Level: logrus.ErrorLevel, Caller: caller}
+
+	s := newSampler(LogConfig{SampleInitial: 2, SampleThereafter: 3, SampleInterval: time.Hour})
+	var allowed []int
+	for i := 1; i <= 10; i++ {
+		if s.allow(entry, now) {
+			allowed = append(allowed, i)
+		}
+	}
+	if want := []int{1, 2, 5, 8}; !slices.Equal(allowed, want) {
+		t.Errorf("allowed = %v, want %v", allowed, want)
+	}
+	// 新的采样窗口
+	if !s.allow(entry, now.Add(time.Hour)) {
+		t.Error("first entry of a new window should be allowed")
+	}
+	if !s.allow(&logrus.Entry{Level: logrus.FatalLevel, Caller: caller}, now) {
+		t.Error("fatal entries should never be sampled")
+	}
+	summaries := s.takeDropped()
+	if len(summaries) != 1 || summaries[0].dropped != 6 || summaries[0].site != "main.go:12" {
+		t.Errorf("summaries = %+v", summaries)
+	}
+	if summaries := s.takeDropped(); len(summaries) != 0 {
+		t.Errorf("dropped counts should be reset, got %+v", summaries)
+	}
+
+	s = newSampler(LogConfig{RateLimit: 1, RateBurst: 2})
+	var got []bool
+	for _, d := range []time.Duration{0, 0, 0, time.Second, time.Second, 3 * time.Second} {
+		got = append(got, s.allow(entry, now.Add(d)))
+	}
+	if want := []bool{true, true, false, true, false, true}; !slices.Equal(got, want) {
+		t.Errorf("rate limit allowed = %v, want %v", got, want)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:              dir,
+		DisableWriterBuffer: true,
+		SampleInitial:       3,
+		SampleInterval:      time.Hour,
+		DropSummaryInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	var console bytes.Buffer
+	logger.SetOutput(&console)
+	seen := &dataHook{}
+	logger.AddHook(seen)
+
+	for i := 0; i < 100; i++ {
+		logger.Error("boom")
+	}
+	// 丢弃标记不能出现在其他hook看到的字段中
+	if seen.fields != 0 {
+		t.Errorf("other hooks saw %d fields, want none", seen.fields)
+	}
+	logger.Info("other site")
+
+	path := filepath.Join(dir, "default.log")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(content), "dropped=97") {
+			if n := strings.Count(string(content), "boom"); n != 3 {
+				t.Errorf("got %d sampled entries in file, want 3", n)
+			}
+			if !strings.Contains(string(content), "other site") {
+				t.Error("entries of other call sites should not be sampled")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no drop summary in %q", content)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(console.String(), "boom"); n != 3 {
+		t.Errorf("got %d sampled entries in console, want 3", n)
+	}
+}
+
+// 统计entry.Data中的字段数
+type dataHook struct{ fields int }
+
+func (h *dataHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *dataHook) Fire(entry *logrus.Entry) error {
+	h.fields += len(entry.Data)
+	return nil
+}
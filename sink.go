@@ -0,0 +1,451 @@
+package mylog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sink is an output of the logger besides the console, e.g. the log files, stderr or a network target.
+//
+// The methods of a sink attached by LogConfig.Sinks are called from one goroutine,
+// so they don't need to be safe for concurrent use.
+type Sink interface {
+	// Write writes a formatted entry. The sink may buffer it until Flush.
+	Write(entry SinkEntry) error
+	// Flush writes the buffered entries.
+	Flush() error
+	// Close flushes and releases the sink, Write is not called after Close.
+	Close() error
+}
+
+// SinkEntry is an entry formatted for a Sink.
+type SinkEntry struct {
+	Time    time.Time
+	Level   logrus.Level
+	Message string
+	// A copy of the fields of the entry, including FILE and FUNC when caller information is enabled.
+	// It is shared by all sinks and must not be modified.
+	Data logrus.Fields
+	// The entry formatted by SinkConfig.Formatter, it must not be modified.
+	Line []byte
+}
+
+// SinkConfig attaches a Sink to the logger.
+type SinkConfig struct {
+	// Name of the sink in error messages and GetSinkStats, default is sink0, sink1, ...
+	Name string
+	Sink Sink
+	// Minimum level of the entries written to the sink, default is the level of the logger.
+	Level string
+	// Formatter of the entries, default is the formatter of the log files (the console formatter without colors).
+	Formatter logrus.Formatter
+	// Maximum number of entries waiting for the sink, default is 1024. Entries are dropped when it is full,
+	// so a slow or broken sink never blocks logging or the log files.
+	BufferSize int
+	// Interval of Flush, default is 1 second unless the sink has its own default (see HTTPExporter.Sink).
+	FlushInterval time.Duration
+}
+
+const defaultSinkBufferSize = 1024
+
+func sinkName(s SinkConfig, i int) string {
+	if s.Name == "" {
+		return fmt.Sprintf("sink%d", i)
+	}
+	return s.Name
+}
+
+func validateSinks(sinks []SinkConfig) error {
+	names := make(map[string]bool, len(sinks))
+	for i, s := range sinks {
+		s.Name = sinkName(s, i)
+		if names[s.Name] {
+			return fmt.Errorf("duplicate sink name %q", s.Name)
+		}
+		names[s.Name] = true
+		if s.Sink == nil {
+			return fmt.Errorf("sink %q: Sink is nil", s.Name)
+		}
+		if s.Level != "" {
+			if _, err := parseLevelStrict(s.Level); err != nil {
+				return fmt.Errorf("sink %q: %w", s.Name, err)
+			}
+		}
+		if s.BufferSize < 0 {
+			return fmt.Errorf("sink %q: BufferSize must not be negative: %d", s.Name, s.BufferSize)
+		}
+	}
+	return nil
+}
+
+// SinkStats is the state of a sink attached by LogConfig.Sinks.
+type SinkStats struct {
+	Name string
+	// Entries waiting for the sink
+	Len int
+	// Maximum number of entries waiting for the sink
+	Capacity int
+	// Entries dropped because the sink was too slow
+	Dropped uint64
+	// Failed calls of Write and Flush
+	Errors uint64
+}
+
+// GetSinkStats returns the state of the sinks of the mylog hook attached to the logger.
+func GetSinkStats(logger *logrus.Logger) []SinkStats {
+	hooks := findLogHooks(logger)
+	if len(hooks) == 0 {
+		return nil
+	}
+	var stats []SinkStats
+	for _, r := range hooks[0].sinks {
+		stats = append(stats, SinkStats{
+			Name:     r.name,
+			Len:      len(r.entries),
+			Capacity: cap(r.entries),
+			Dropped:  r.dropped.Load(),
+			Errors:   r.errors.Load(),
+		})
+	}
+	return stats
+}
+
+// 日志文件作为Sink，由Fire同步调用Write
+type fileSink struct {
+	hook *logHook
+}
+
+func (s fileSink) Write(entry SinkEntry) error {
+	return s.hook.writeLine(entry.Level, entry.Line)
+}
+
+// 写入队列和缓冲中的日志(不fsync)
+func (s fileSink) Flush() error {
+	hook := s.hook
+	hook.WriterLock.Lock()
+	defer hook.WriterLock.Unlock()
+	if hook.closed {
+		return nil
+	}
+	hook.drainQueue()
+	if hook.OtherBufWriter != nil {
+		return hook.OtherBufWriter.Flush()
+	}
+	return nil
+}
+
+// 写入剩余的日志，fsync后关闭所有日志文件，之后不再写入
+func (s fileSink) Close() error {
+	hook := s.hook
+	hook.WriterLock.Lock()
+	defer hook.WriterLock.Unlock()
+	if hook.closed {
+		return nil
+	}
+	hook.closed = true
+
+	var errs []error
+	hook.drainQueue()
+	hook.bufferQueue.close()
+	if hook.OtherBufWriter != nil {
+		if err := hook.OtherBufWriter.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if hook.OtherWriter != nil {
+		if err := hook.OtherWriter.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+		if err := hook.OtherWriter.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, w := range hook.lazyWriters() {
+		if !w.IsCreated() {
+			continue
+		}
+		if err := w.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+		if err := w.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 使用日志目录的Sink(如TCP sink的spool)，在创建logger时设置
+type logDirSetter interface {
+	setLogDir(dir string)
+}
+
+// 关闭时使用Shutdown的ctx限制发送时间的Sink(如HTTP exporter)
+type contextCloser interface {
+	closeContext(ctx context.Context) error
+}
+
+// 未设置SinkConfig.Formatter时使用自己的formatter的Sink
+type formatterProvider interface {
+	defaultFormatter() logrus.Formatter
+}
+
+// 未设置SinkConfig.FlushInterval时使用自己的刷新间隔的Sink(如按BatchWait发送的HTTP exporter)
+type flushIntervalProvider interface {
+	defaultFlushInterval() time.Duration
+}
+
+// 在独立的goroutine中写入LogConfig.Sinks中的一个Sink，队列满时丢弃
+type sinkRunner struct {
+	name          string
+	sink          Sink
+	level         logrus.Level
+	formatter     logrus.Formatter
+	flushInterval time.Duration
+	entries       chan SinkEntry
+	dropped       atomic.Uint64
+	errors        atomic.Uint64
+	// 关闭后run写入剩余的日志并关闭Sink
+	stop chan struct{}
+	// 关闭stop之前设置
+	stopCtx  context.Context
+	done     chan struct{}
+	closeErr error
+}
+
+func newSinkRunner(config SinkConfig, i int) *sinkRunner {
+	r := &sinkRunner{
+		name:          sinkName(config, i),
+		sink:          config.Sink,
+		level:         logrus.TraceLevel,
+		formatter:     config.Formatter,
+		flushInterval: config.FlushInterval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if config.Level != "" {
+		r.level = PraseLevel(config.Level)
+	}
+	if p, ok := config.Sink.(formatterProvider); ok && r.formatter == nil {
+		r.formatter = p.defaultFormatter()
+	}
+	if p, ok := config.Sink.(flushIntervalProvider); ok && r.flushInterval <= 0 {
+		r.flushInterval = p.defaultFlushInterval()
+	}
+	if r.flushInterval <= 0 {
+		r.flushInterval = time.Second
+	}
+	size := config.BufferSize
+	if size <= 0 {
+		size = defaultSinkBufferSize
+	}
+	r.entries = make(chan SinkEntry, size)
+	return r
+}
+
+func (r *sinkRunner) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+	// 连续失败时只输出第一个错误
+	failing := false
+	report := func(op string, err error) {
+		if err == nil {
+			failing = false
+			return
+		}
+		r.errors.Add(1)
+		if !failing {
+			fmt.Fprintf(os.Stderr, "mylog: sink %s %s err:%v\n", r.name, op, err)
+		}
+		failing = true
+	}
+	for {
+		select {
+		case entry := <-r.entries:
+			report("write", r.sink.Write(entry))
+		case <-ticker.C:
+			// 没有新日志时同样调用，Sink可以在Flush中重试之前失败的发送
+			report("flush", r.sink.Flush())
+		case <-r.stop:
+			// 只有run读取，不会阻塞
+			for len(r.entries) > 0 {
+				report("write", r.sink.Write(<-r.entries))
+			}
+			if c, ok := r.sink.(contextCloser); ok {
+				r.closeErr = c.closeContext(r.stopCtx)
+			} else {
+				r.closeErr = r.sink.Close()
+			}
+			return
+		}
+	}
+}
+
+// 不阻塞，队列满时丢弃
+func (r *sinkRunner) push(entry SinkEntry) {
+	select {
+	case r.entries <- entry:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// 按各Sink的级别和formatter格式化后入队，未设置formatter的Sink共用日志文件的格式
+func (hook *logHook) fireSinks(entry *logrus.Entry) {
+	var fileLine []byte
+	var data logrus.Fields
+	for _, r := range hook.sinks {
+		if entry.Level > r.level {
+			continue
+		}
+		var line []byte
+		var err error
+		switch {
+		case r.formatter != nil:
+			line, err = r.formatter.Format(entry)
+		case fileLine != nil:
+			line = fileLine
+		default:
+			line, err = hook.format(entry)
+			line = eliminateColor(line)
+			fileLine = line
+		}
+		if err != nil {
+			r.errors.Add(1)
+			continue
+		}
+		if data == nil {
+			// entry.Data在Fire返回后会被修改
+			data = maps.Clone(entry.Data)
+		}
+		r.push(SinkEntry{Time: entry.Time, Level: entry.Level, Message: entry.Message, Data: data, Line: line})
+	}
+}
+
+// 通知所有Sink写入剩余的日志并关闭，等待到ctx结束
+func (hook *logHook) closeSinks(ctx context.Context) error {
+	for _, r := range hook.sinks {
+		r.stopCtx = ctx
+		close(r.stop)
+	}
+	var errs []error
+	for _, r := range hook.sinks {
+		select {
+		case <-r.done:
+			if r.closeErr != nil {
+				errs = append(errs, fmt.Errorf("sink %s: %w", r.name, r.closeErr))
+			}
+		case <-ctx.Done():
+			return errors.Join(append(errs, fmt.Errorf("sink %s: %w", r.name, ctx.Err()))...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewWriterSink returns a Sink writing the entries to w, e.g. os.Stderr.
+// Flush calls the Flush method of w if it has one (e.g. *bufio.Writer).
+// Close closes w if it is an io.Closer, except os.Stdout and os.Stderr.
+func NewWriterSink(w io.Writer) Sink {
+	return writerSink{w: w}
+}
+
+type writerSink struct {
+	w io.Writer
+}
+
+func (s writerSink) Write(entry SinkEntry) error {
+	_, err := s.w.Write(entry.Line)
+	return err
+}
+
+func (s writerSink) Flush() error {
+	if f, ok := s.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (s writerSink) Close() error {
+	err := s.Flush()
+	if s.w == os.Stdout || s.w == os.Stderr {
+		return err
+	}
+	if c, ok := s.w.(io.Closer); ok {
+		return errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// 连接失败后按指数退避重连，只在一个goroutine中使用
+type redialer struct {
+	network string
+	addr    string
+	timeout time.Duration
+	minWait time.Duration
+	maxWait time.Duration
+	// 当前的等待时间和下次可以连接的时间
+	backoff time.Duration
+	next    time.Time
+}
+
+// minWait默认100毫秒，maxWait默认30秒
+func newRedialer(network, addr string, timeout, minWait, maxWait time.Duration) redialer {
+	if minWait <= 0 {
+		minWait = 100 * time.Millisecond
+	}
+	if maxWait <= 0 {
+		maxWait = 30 * time.Second
+	}
+	return redialer{network: network, addr: addr, timeout: timeout, minWait: minWait, maxWait: max(maxWait, minWait)}
+}
+
+// 是否已过等待时间
+func (d *redialer) ready() bool {
+	return !time.Now().Before(d.next)
+}
+
+// 连接失败时推迟下次连接
+func (d *redialer) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout(d.network, d.addr, d.timeout)
+	if err != nil {
+		d.fail()
+		return nil, err
+	}
+	d.backoff = 0
+	return conn, nil
+}
+
+// 连接或写入失败，等待时间加倍
+func (d *redialer) fail() {
+	if d.backoff == 0 {
+		d.backoff = d.minWait
+	} else {
+		d.backoff = min(d.backoff*2, d.maxWait)
+	}
+	d.next = time.Now().Add(d.backoff)
+}
+
+// 下次立即连接，不改变等待时间
+func (d *redialer) retryNow() {
+	d.next = time.Time{}
+}
+
+// 检查对端是否已关闭连接，用于只写不读的连接(TCP sink、syslog)。
+// 对端关闭后的第一次写入通常仍会成功，数据却已丢失，所以在写入之前检查。
+// 代价是每次检查阻塞最多1毫秒，并且会丢弃对端发送的1个字节(这些服务端不发送数据)。
+func connAlive(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	conn.SetReadDeadline(time.Time{})
+	return err == nil || errors.Is(err, os.ErrDeadlineExceeded)
+}
@@ -0,0 +1,172 @@
+package mylog
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 记录写入的日志，用于测试
+type memorySink struct {
+	mu      sync.Mutex
+	lines   []string
+	flushed int
+	closed  bool
+	// 阻塞Write直到关闭
+	block chan struct{}
+	err   error
+}
+
+func (s *memorySink) Write(entry SinkEntry) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.lines = append(s.lines, string(entry.Line))
+	return nil
+}
+
+func (s *memorySink) Flush() error {
+	s.mu.Lock()
+	s.flushed++
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memorySink) flushes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushed
+}
+
+func (s *memorySink) all() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.lines, "")
+}
+
+func TestSinks(t *testing.T) {
+	dir := t.TempDir()
+	all, warn := &memorySink{}, &memorySink{}
+	broken := &memorySink{err: errors.New("connection refused")}
+	logger, err := NewLogger(LogConfig{
+		LogDir:    dir,
+		NoConsole: true,
+		LogLevel:  DebugLevel,
+		Sinks: []SinkConfig{
+			{Sink: all, FlushInterval: 10 * time.Millisecond},
+			{Name: "warn", Sink: warn, Level: WarnLevel, Formatter: &logrus.JSONFormatter{}},
+			{Name: "broken", Sink: broken},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("debug entry")
+	logger.Warn("warn entry")
+
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(all.all(), "warn entry") || all.flushes() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for sink0")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := all.all(); !strings.Contains(got, "debug entry") || !strings.Contains(got, "WARN[") || strings.Contains(got, "\x1b[") {
+		t.Errorf("sink0 = %q, want both entries in the file format", got)
+	}
+	stats := GetSinkStats(logger)
+	if len(stats) != 3 || stats[0].Name != "sink0" || stats[2].Name != "broken" {
+		t.Fatalf("GetSinkStats() = %+v", stats)
+	}
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := warn.all(); strings.Contains(got, "debug entry") || !strings.Contains(got, `"msg":"warn entry"`) {
+		t.Errorf("warn sink = %q, want the warn entry in JSON", got)
+	}
+	if !all.closed || !warn.closed || !broken.closed {
+		t.Error("sinks should be closed")
+	}
+	if stats := GetSinkStats(logger); stats != nil {
+		t.Errorf("GetSinkStats() after Close = %+v", stats)
+	}
+	if content := readLog(t, filepath.Join(dir, "default.log")); !strings.Contains(content, "warn entry") {
+		t.Errorf("log file = %q", content)
+	}
+}
+
+func TestSinkIsolation(t *testing.T) {
+	dir := t.TempDir()
+	slow := &memorySink{block: make(chan struct{})}
+	logger, err := NewLogger(LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		DisableWriterBuffer: true,
+		Sinks:               []SinkConfig{{Name: "slow", Sink: slow, BufferSize: 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			logger.Info("entry ", i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked sink should not block logging")
+	}
+	if content := readLog(t, filepath.Join(dir, "default.log")); !strings.Contains(content, "entry 9") {
+		t.Errorf("log file = %q", content)
+	}
+	// 第一条日志可能已被取出并阻塞在Write中
+	if stats := GetSinkStats(logger); stats[0].Dropped < 7 {
+		t.Errorf("GetSinkStats() = %+v, want at least 7 dropped", stats)
+	}
+	close(slow.block)
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:         dir,
+		NoConsole:      true,
+		LogFileDisable: true,
+		Sinks:          []SinkConfig{{Sink: NewWriterSink(&buf)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("to the writer")
+	if err := Close(logger); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "to the writer") {
+		t.Errorf("writer = %q", buf.String())
+	}
+}
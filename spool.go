@@ -0,0 +1,141 @@
+package mylog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const spoolExt = ".spool"
+
+// 磁盘上的有界队列，由按序号命名的分段文件组成(000000000001.spool)，只在一个goroutine中使用
+type spool struct {
+	dir         string
+	maxSize     int64
+	segmentSize int64
+	// 按序号排列的分段，最后一个可能正在写入
+	segments []spoolSegment
+	size     int64
+	nextSeq  uint64
+	// 正在写入的分段
+	file *os.File
+	// 因超过maxSize删除的字节数
+	dropped int64
+}
+
+type spoolSegment struct {
+	path string
+	size int64
+}
+
+// 打开目录并加载上次运行留下的分段
+func openSpool(dir string, maxSize int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{dir: dir, maxSize: maxSize, nextSeq: 1}
+	s.segmentSize = max(min(maxSize/8, 4<<20), 4<<10)
+	var seqs []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, spoolExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+	for _, seq := range seqs {
+		path := s.segmentPath(seq)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, spoolSegment{path: path, size: info.Size()})
+		s.size += info.Size()
+		s.nextSeq = seq + 1
+	}
+	return s, nil
+}
+
+func (s *spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%012d%s", seq, spoolExt))
+}
+
+func (s *spool) empty() bool {
+	return len(s.segments) == 0
+}
+
+// 写入到最后的分段，超过maxSize时删除最旧的分段
+func (s *spool) write(data []byte) error {
+	if s.file == nil || s.segments[len(s.segments)-1].size >= s.segmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	for s.size+int64(len(data)) > s.maxSize && len(s.segments) > 1 {
+		oldest := s.segments[0]
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		s.segments = s.segments[1:]
+		s.size -= oldest.size
+		s.dropped += oldest.size
+	}
+	n, err := s.file.Write(data)
+	s.segments[len(s.segments)-1].size += int64(n)
+	s.size += int64(n)
+	return err
+}
+
+// 关闭正在写入的分段并创建新的分段
+func (s *spool) rotate() error {
+	s.closeFile()
+	path := s.segmentPath(s.nextSeq)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.nextSeq++
+	s.file = file
+	s.segments = append(s.segments, spoolSegment{path: path})
+	return nil
+}
+
+// 读取最旧的分段，正在写入的分段会先被关闭，之后的写入使用新的分段
+func (s *spool) oldest() ([]byte, error) {
+	if len(s.segments) == 1 {
+		s.closeFile()
+	}
+	return os.ReadFile(s.segments[0].path)
+}
+
+// 发送成功后删除最旧的分段
+func (s *spool) removeOldest() error {
+	oldest := s.segments[0]
+	s.segments = s.segments[1:]
+	s.size -= oldest.size
+	if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *spool) closeFile() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,94 @@
+package mylog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sync writes the queued and buffered entries of the mylog hooks attached to the logger to the files
+// and commits the files to stable storage (fsync). Everything logged before the call is durable when it returns nil.
+func Sync(logger *logrus.Logger) error {
+	var errs []error
+	for _, hook := range findLogHooks(logger) {
+		if err := hook.sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 写入队列和缓冲中的日志并fsync
+func (hook *logHook) sync() error {
+	hook.WriterLock.Lock()
+	defer hook.WriterLock.Unlock()
+	if hook.closed {
+		return nil
+	}
+	hook.drainQueue()
+	return hook.fsync()
+}
+
+// 写入缓冲中的日志并fsync，必须持有WriterLock调用，开启缓冲时只能在bufferFlusher中或持有写锁调用
+func (hook *logHook) fsync() error {
+	var errs []error
+	if hook.OtherBufWriter != nil {
+		if err := hook.OtherBufWriter.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if hook.OtherWriter != nil {
+		if err := hook.OtherWriter.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, w := range hook.lazyWriters() {
+		if w.IsCreated() {
+			if err := w.Sync(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	hook.unsynced.Store(0)
+	return errors.Join(errs...)
+}
+
+// 未fsync的数据达到SyncBytes时fsync，调用要求同fsync
+func (hook *logHook) syncIfNeeded() {
+	if n := hook.LogConfig.SyncBytes; n > 0 && hook.unsynced.Load() >= n {
+		if err := hook.fsync(); err != nil {
+			fmt.Fprintln(os.Stderr, "fsync err:", err)
+		}
+	}
+}
+
+// 每隔SyncInterval fsync一次(有新写入时)
+func (hook *logHook) syncTimer() {
+	defer hook.wg.Done()
+	timer := time.NewTimer(time.Second)
+	defer timer.Stop()
+	for {
+		hook.WriterLock.RLock()
+		interval := hook.LogConfig.SyncInterval
+		hook.WriterLock.RUnlock()
+		enabled := interval > 0
+		if !enabled {
+			// 可能通过UpdateConfig开启
+			interval = time.Second
+		}
+		timer.Reset(interval)
+		select {
+		case <-hook.ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if enabled && hook.unsynced.Load() > 0 {
+			if err := hook.sync(); err != nil {
+				fmt.Fprintln(os.Stderr, "fsync err:", err)
+			}
+		}
+	}
+}
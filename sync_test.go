@@ -0,0 +1,76 @@
+package mylog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:           dir,
+		NoConsole:        true,
+		WriterBufferSize: 1 << 20,
+		FlushInterval:    time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	hook := findLogHooks(logger)[0]
+
+	for i := 0; i < 10; i++ {
+		logger.Info("durable")
+	}
+	if err := Sync(logger); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "default.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(content), "durable"); n != 10 {
+		t.Errorf("got %d entries after Sync, want 10", n)
+	}
+	if n := hook.unsynced.Load(); n != 0 {
+		t.Errorf("unsynced = %d after Sync", n)
+	}
+}
+
+func TestSyncPolicy(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(LogConfig{
+		LogDir:              dir,
+		NoConsole:           true,
+		DisableWriterBuffer: true,
+		SyncBytes:           1 << 20,
+		SyncOnError:         true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	hook := findLogHooks(logger)[0]
+
+	logger.Info("not synced yet")
+	if hook.unsynced.Load() == 0 {
+		t.Fatal("unsynced should count written bytes")
+	}
+	logger.Error("synced")
+	if n := hook.unsynced.Load(); n != 0 {
+		t.Errorf("unsynced = %d after an error entry with SyncOnError", n)
+	}
+
+	config := hook.LogConfig
+	config.SyncBytes = 1
+	if err := UpdateConfig(logger, config); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("synced by size")
+	if n := hook.unsynced.Load(); n != 0 {
+		t.Errorf("unsynced = %d after reaching SyncBytes", n)
+	}
+}
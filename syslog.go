@@ -0,0 +1,367 @@
+package mylog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SyslogFormat is the message format of a syslog sink.
+type SyslogFormat string
+
+const (
+	// SyslogRFC5424 is the format of RFC 5424, the fields of the entries are sent as structured data
+	// and the message of the entry as MSG.
+	SyslogRFC5424 SyslogFormat = "rfc5424"
+	// SyslogRFC3164 is the BSD syslog format, it has no structured data so the entry
+	// formatted by SinkConfig.Formatter is sent as MSG.
+	SyslogRFC3164 SyslogFormat = "rfc3164"
+)
+
+// SyslogFraming is how messages are delimited on stream connections (tcp and unix).
+type SyslogFraming string
+
+const (
+	// SyslogOctetCounting prefixes every message with its length (RFC 6587), messages may contain newlines.
+	SyslogOctetCounting SyslogFraming = "octet_counting"
+	// SyslogNonTransparent terminates every message with a newline, newlines in messages are replaced by spaces.
+	SyslogNonTransparent SyslogFraming = "non_transparent"
+)
+
+// SyslogConfig is the configuration of NewSyslogSink.
+type SyslogConfig struct {
+	// Network of the syslog server: udp, tcp, unix (stream) or unixgram, default is udp.
+	Network string
+	// Address of the syslog server, e.g. localhost:514 or /dev/log.
+	Addr string
+	// Message format, default is rfc5424.
+	Format SyslogFormat
+	// Framing on tcp and unix connections, default is octet_counting.
+	Framing SyslogFraming
+	// Facility code 1-23, e.g. 16 is local0. Default (0) is 1 (user), so the kern facility (0) can not be used.
+	Facility int
+	// APP-NAME (TAG in rfc3164), default is the name of the executable.
+	AppName string
+	// HOSTNAME, default is os.Hostname().
+	Hostname string
+	// SD-ID of the structured data with the fields of the entries, default is fields@32473.
+	StructuredDataID string
+	// Timeout of connecting and writing, default is 5 seconds.
+	Timeout time.Duration
+	// Waiting time before reconnecting after a failure, doubled after every failed attempt
+	// from ReconnectMin (default 100 milliseconds) up to ReconnectMax (default 30 seconds).
+	// Entries written while waiting are dropped.
+	ReconnectMin time.Duration
+	ReconnectMax time.Duration
+}
+
+// ErrSyslogNotConnected is returned by the syslog sink for entries dropped while waiting to reconnect.
+var ErrSyslogNotConnected = errors.New("syslog: not connected")
+
+// NewSyslogSink returns a Sink sending the entries to a syslog server, use it in LogConfig.Sinks.
+// Levels are mapped to severities: panic is alert, fatal is crit, error is err, warn is warning,
+// info is info, debug and trace are debug.
+//
+// The server is connected on the first entry and reconnected with backoff after a failure,
+// so the logger can be created while the server is down. On tcp and unix connections the messages
+// are buffered until Flush, messages that failed to send are sent again after reconnecting.
+// Before sending them Flush checks that the server has not closed the connection, which blocks for up to 1 millisecond.
+func NewSyslogSink(config SyslogConfig) (Sink, error) {
+	if config.Network == "" {
+		config.Network = "udp"
+	}
+	switch config.Network {
+	case "udp", "udp4", "udp6", "unixgram", "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("syslog: unsupported network %q", config.Network)
+	}
+	if config.Addr == "" {
+		return nil, errors.New("syslog: Addr is empty")
+	}
+	if config.Format == "" {
+		config.Format = SyslogRFC5424
+	}
+	if config.Format != SyslogRFC5424 && config.Format != SyslogRFC3164 {
+		return nil, fmt.Errorf("syslog: unknown format %q", config.Format)
+	}
+	if config.Framing == "" {
+		config.Framing = SyslogOctetCounting
+	}
+	if config.Framing != SyslogOctetCounting && config.Framing != SyslogNonTransparent {
+		return nil, fmt.Errorf("syslog: unknown framing %q", config.Framing)
+	}
+	if config.Facility == 0 {
+		config.Facility = 1
+	}
+	if config.Facility < 1 || config.Facility > 23 {
+		return nil, fmt.Errorf("syslog: facility must be 1-23: %d", config.Facility)
+	}
+	if config.AppName == "" {
+		config.AppName = filepath.Base(os.Args[0])
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.StructuredDataID == "" {
+		config.StructuredDataID = "fields@32473"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	return &syslogSink{
+		config:   config,
+		dialer:   newRedialer(config.Network, config.Addr, config.Timeout, config.ReconnectMin, config.ReconnectMax),
+		stream:   config.Network != "unixgram" && config.Network[:3] != "udp",
+		hostname: syslogHeaderField(config.Hostname, 255),
+		appName:  syslogHeaderField(config.AppName, 48),
+		sdID:     syslogParamName(config.StructuredDataID),
+		pid:      strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+// 流式连接中等待的消息达到该大小时发送
+const syslogBatchSize = 4096
+
+// Sink的方法只在一个goroutine中调用，不需要加锁
+type syslogSink struct {
+	config   SyslogConfig
+	stream   bool
+	hostname string
+	appName  string
+	sdID     string
+	pid      string
+
+	dialer redialer
+	conn   net.Conn
+	// 流式连接中已分帧、等待写入连接的消息。写入失败时保留，重连后重新发送
+	pending []byte
+	// 复用的消息缓冲
+	buf bytes.Buffer
+}
+
+func (s *syslogSink) Write(entry SinkEntry) error {
+	msg := s.format(entry)
+	if err := s.connect(); err != nil {
+		return err
+	}
+	if !s.stream {
+		// 数据报，每条消息一个包
+		return s.withReconnect(func() error {
+			s.conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+			_, err := s.conn.Write(msg)
+			return err
+		})
+	}
+	s.appendMessage(msg)
+	if len(s.pending) < syslogBatchSize {
+		return nil
+	}
+	return s.Flush()
+}
+
+// 写入流式连接中等待的消息，失败时保留，重连后重新发送
+func (s *syslogSink) Flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	if s.conn != nil && !connAlive(s.conn) {
+		// 服务端已关闭连接，写入可能成功但不会被接收
+		s.closeConn()
+		s.dialer.retryNow()
+	}
+	if s.conn == nil && !s.dialer.ready() {
+		return nil
+	}
+	if err := s.connect(); err != nil {
+		return err
+	}
+	return s.withReconnect(func() error {
+		s.conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+		if _, err := s.conn.Write(s.pending); err != nil {
+			return err
+		}
+		s.pending = s.pending[:0]
+		return nil
+	})
+}
+
+// 未能发送的消息无法保留，返回错误
+func (s *syslogSink) Close() error {
+	err := s.Flush()
+	if len(s.pending) > 0 {
+		err = errors.Join(err, fmt.Errorf("syslog: %d bytes of messages not sent", len(s.pending)))
+		s.pending = s.pending[:0]
+	}
+	s.closeConn()
+	return err
+}
+
+// 未连接时连接服务端，重连等待中返回ErrSyslogNotConnected
+func (s *syslogSink) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+	if !s.dialer.ready() {
+		return ErrSyslogNotConnected
+	}
+	conn, err := s.dialer.dial()
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// 写入失败时服务端可能已重启，立即重连并重试一次
+func (s *syslogSink) withReconnect(write func() error) error {
+	if err := write(); err == nil {
+		return nil
+	}
+	s.closeConn()
+	s.dialer.retryNow()
+	if err := s.connect(); err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		// 关闭连接并推迟下次重连
+		s.closeConn()
+		s.dialer.fail()
+		return err
+	}
+	return nil
+}
+
+func (s *syslogSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// 按Framing分帧后加入等待发送的消息
+func (s *syslogSink) appendMessage(msg []byte) {
+	if s.config.Framing == SyslogOctetCounting {
+		s.pending = strconv.AppendInt(s.pending, int64(len(msg)), 10)
+		s.pending = append(s.pending, ' ')
+		s.pending = append(s.pending, msg...)
+		return
+	}
+	for _, c := range msg {
+		if c == '\n' {
+			c = ' '
+		}
+		s.pending = append(s.pending, c)
+	}
+	s.pending = append(s.pending, '\n')
+}
+
+// 生成不含分帧的syslog消息，返回的切片在下次调用前有效
+func (s *syslogSink) format(entry SinkEntry) []byte {
+	b := &s.buf
+	b.Reset()
+	pri := s.config.Facility*8 + syslogSeverity(entry.Level)
+	if s.config.Format == SyslogRFC3164 {
+		// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+		fmt.Fprintf(b, "<%d>%s %s %s[%s]: ", pri, entry.Time.Format(time.Stamp), s.hostname, s.appName, s.pid)
+		b.Write(bytes.TrimRight(entry.Line, "\r\n"))
+		return b.Bytes()
+	}
+	// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID PARAM="VALUE" ...] MSG
+	fmt.Fprintf(b, "<%d>1 %s %s %s %s - ", pri, entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname, s.appName, s.pid)
+	s.writeStructuredData(b, entry.Data)
+	if entry.Message != "" {
+		b.WriteByte(' ')
+		b.WriteString(entry.Message)
+	}
+	return b.Bytes()
+}
+
+// 按key排序写入结构化数据，没有字段时为-
+func (s *syslogSink) writeStructuredData(b *bytes.Buffer, data logrus.Fields) {
+	if len(data) == 0 {
+		b.WriteByte('-')
+		return
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	b.WriteByte('[')
+	b.WriteString(s.sdID)
+	for _, key := range keys {
+		b.WriteByte(' ')
+		b.WriteString(syslogParamName(key))
+		b.WriteString(`="`)
+		var value string
+		switch v := data[key].(type) {
+		case error:
+			value = v.Error()
+		default:
+			value = fmt.Sprint(v)
+		}
+		for _, c := range []byte(value) {
+			// PARAM-VALUE中需要转义的字符
+			if c == '"' || c == '\\' || c == ']' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte(']')
+}
+
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel:
+		return 1
+	case logrus.FatalLevel:
+		return 2
+	case logrus.ErrorLevel:
+		return 3
+	case logrus.WarnLevel:
+		return 4
+	case logrus.InfoLevel:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// 头部字段只能包含可打印的ASCII字符(不含空格)，为空时为-
+func syslogHeaderField(s string, maxLen int) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c < 33 || c > 126 {
+			b[i] = '_'
+		}
+	}
+	if len(b) > maxLen {
+		b = b[:maxLen]
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+// SD-NAME不能包含= ] "和空格，最长32个字符
+func syslogParamName(s string) string {
+	b := []byte(syslogHeaderField(s, 32))
+	for i, c := range b {
+		if c == '=' || c == ']' || c == '"' {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
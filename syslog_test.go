@@ -0,0 +1,219 @@
+package mylog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func Test_syslogSink_format(t *testing.T) {
+	sink, err := NewSyslogSink(SyslogConfig{Addr: "localhost:514", AppName: "my app", Hostname: "host", Facility: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sink.(*syslogSink)
+	entry := SinkEntry{
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC),
+		Level:   logrus.ErrorLevel,
+		Message: "query failed",
+		Data:    logrus.Fields{"FILE": "db.go:12", "error": errors.New(`bad "sql"]`), "a=b": 1},
+		Line:    []byte("ERRO query failed\n"),
+	}
+	want := `<131>1 2024-01-02T03:04:05.000006Z host my_app ` + s.pid +
+		` - [fields@32473 FILE="db.go:12" a_b="1" error="bad \"sql\"\]"] query failed`
+	if got := string(s.format(entry)); got != want {
+		t.Errorf("rfc5424 = %s\nwant %s", got, want)
+	}
+	entry.Data = nil
+	if got := string(s.format(entry)); !strings.HasSuffix(got, " - - query failed") {
+		t.Errorf("rfc5424 without fields = %s", got)
+	}
+
+	s.config.Format = SyslogRFC3164
+	want = `<131>Jan  2 03:04:05 host my_app[` + s.pid + `]: ERRO query failed`
+	if got := string(s.format(entry)); got != want {
+		t.Errorf("rfc3164 = %s\nwant %s", got, want)
+	}
+
+	for _, config := range []SyslogConfig{{}, {Addr: "x", Network: "ip"}, {Addr: "x", Format: "rfc1"}, {Addr: "x", Facility: 24}, {Addr: "x", Facility: -1}} {
+		if _, err := NewSyslogSink(config); err == nil {
+			t.Errorf("NewSyslogSink(%+v) should fail", config)
+		}
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	sink, err := NewSyslogSink(SyslogConfig{Addr: pc.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := NewLogger(LogConfig{LogFileDisable: true, NoConsole: true, Sinks: []SinkConfig{{Sink: sink}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	logger.WithField("user", "tom").Warn("disk almost full")
+
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<12>1 ") || !strings.Contains(msg, `FILE="module/syslog_test.go:`) ||
+		!strings.Contains(msg, `user="tom"`) || !strings.HasSuffix(msg, "] disk almost full") {
+		t.Errorf("message = %s", msg)
+	}
+}
+
+// 读取一条按长度分帧的消息
+func readOctetCounted(r *bufio.Reader) (string, error) {
+	length, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, n)
+	_, err = io.ReadFull(r, msg)
+	return string(msg), err
+}
+
+func TestSyslogTCPReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conns := make(chan net.Conn)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				close(conns)
+				return
+			}
+			conns <- conn
+		}
+	}()
+	sink, err := NewSyslogSink(SyslogConfig{Network: "tcp", Addr: ln.Addr().String(), ReconnectMin: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	write := func(msg string) error {
+		if err := sink.Write(SinkEntry{Time: time.Now(), Level: logrus.InfoLevel, Message: msg}); err != nil {
+			return err
+		}
+		return sink.Flush()
+	}
+
+	if err := write("first\nline"); err != nil {
+		t.Fatal(err)
+	}
+	conn := <-conns
+	msg, err := readOctetCounted(bufio.NewReader(conn))
+	if err != nil || !strings.HasSuffix(msg, " - first\nline") {
+		t.Fatalf("message = %q, %v", msg, err)
+	}
+
+	// 服务端断开后重连
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 0; ; i++ {
+		if time.Now().After(deadline) {
+			t.Fatal("the sink did not reconnect")
+		}
+		write("after " + strconv.Itoa(i))
+		select {
+		case conn = <-conns:
+		case <-time.After(20 * time.Millisecond):
+			continue
+		}
+		break
+	}
+	defer conn.Close()
+	msg, err = readOctetCounted(bufio.NewReader(conn))
+	if err != nil || !strings.Contains(msg, " after ") {
+		t.Errorf("message after reconnecting = %q, %v", msg, err)
+	}
+}
+
+func TestSyslogTCPResend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	ln.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	sink, err := NewSyslogSink(SyslogConfig{Network: "tcp", Addr: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	write := func(msg string) {
+		if err := sink.Write(SinkEntry{Time: time.Now(), Level: logrus.InfoLevel, Message: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("first")
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 服务端在发送前断开，缓冲中的消息在重连后发送
+	conn.Close()
+	write("second")
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	conn, err = ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, want := range []string{" - first", " - second"} {
+		msg, err := readOctetCounted(r)
+		if err != nil || !strings.HasSuffix(msg, want) {
+			t.Errorf("message = %q, %v, want suffix %q", msg, err, want)
+		}
+	}
+}
+
+func TestSyslogBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	sink, err := NewSyslogSink(SyslogConfig{Network: "tcp", Addr: addr, ReconnectMin: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := SinkEntry{Time: time.Now(), Level: logrus.InfoLevel, Message: "lost"}
+	if err := sink.Write(entry); err == nil || errors.Is(err, ErrSyslogNotConnected) {
+		t.Errorf("first Write err = %v, want dial error", err)
+	}
+	if err := sink.Write(entry); !errors.Is(err, ErrSyslogNotConnected) {
+		t.Errorf("Write while waiting err = %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build unix
+
+package mylog
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSyslogUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	sink, err := NewSyslogSink(SyslogConfig{Network: "unix", Addr: path, Format: SyslogRFC3164, Framing: SyslogNonTransparent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := NewLogger(LogConfig{
+		LogFileDisable: true,
+		NoConsole:      true,
+		Sinks:          []SinkConfig{{Sink: sink, Formatter: &logrus.TextFormatter{DisableTimestamp: true}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hello\nunix")
+	Close(logger)
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "<14>") || !strings.Contains(line, `]: level=info msg="hello\nunix"`) {
+		t.Errorf("message = %q", line)
+	}
+}
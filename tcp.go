@@ -0,0 +1,202 @@
+package mylog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+// TCPSinkConfig is the configuration of NewTCPSink.
+type TCPSinkConfig struct {
+	// Network of the endpoint, default is tcp.
+	Network string
+	// Address of the endpoint, e.g. logstash:5000.
+	Addr string
+	// Directory of the entries waiting for the endpoint. A relative path is relative to LogDir
+	// of the logger, default is spool (LogDir/spool). Entries left by the previous run are sent first.
+	SpoolDir string
+	// Maximum total size of the spool in bytes, default is 100MB. The oldest entries are dropped when it is full.
+	SpoolMaxSize int64
+	// Entries are sent when BatchSize bytes are buffered and on every Flush, default is 64KB.
+	BatchSize int
+	// Timeout of connecting and writing, default is 5 seconds.
+	Timeout time.Duration
+	// Waiting time before reconnecting after a failure, doubled after every failed attempt
+	// from ReconnectMin (default 100 milliseconds) up to ReconnectMax (default 30 seconds).
+	ReconnectMin time.Duration
+	ReconnectMax time.Duration
+}
+
+// NewTCPSink returns a Sink streaming the entries, one per line, to a TCP endpoint, use it in LogConfig.Sinks.
+// The entries are formatted like the log files (JSON lines with LogConfig.JSONFormat, text otherwise)
+// unless SinkConfig.Formatter is set.
+//
+// While the endpoint can not be reached the entries are written to the spool directory and sent
+// in order after reconnecting. Delivery is at least once: a batch that failed to send is spooled
+// and sent again as a whole, so the endpoint may receive some entries twice.
+func NewTCPSink(config TCPSinkConfig) (Sink, error) {
+	if config.Network == "" {
+		config.Network = "tcp"
+	}
+	switch config.Network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("tcp sink: unsupported network %q", config.Network)
+	}
+	if config.Addr == "" {
+		return nil, errors.New("tcp sink: Addr is empty")
+	}
+	if config.SpoolDir == "" {
+		config.SpoolDir = "spool"
+	}
+	if config.SpoolMaxSize < 0 || config.BatchSize < 0 {
+		return nil, errors.New("tcp sink: SpoolMaxSize and BatchSize must not be negative")
+	}
+	if config.SpoolMaxSize == 0 {
+		config.SpoolMaxSize = 100 << 20
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = 64 << 10
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	return &tcpSink{
+		config:   config,
+		spoolDir: config.SpoolDir,
+		dialer:   newRedialer(config.Network, config.Addr, config.Timeout, config.ReconnectMin, config.ReconnectMax),
+	}, nil
+}
+
+// 每次发送spool的最长时间，剩余的在之后的Flush中发送
+const spoolReplayBudget = time.Second
+
+// Sink的方法只在一个goroutine中调用，不需要加锁
+type tcpSink struct {
+	config   TCPSinkConfig
+	spoolDir string
+	dialer   redialer
+	conn     net.Conn
+	// 等待发送的日志
+	pending []byte
+	// 第一次写入时打开
+	spool    *spool
+	spoolErr error
+	// 已报告的spool丢弃字节数
+	reported int64
+}
+
+// 创建logger时设置spool的相对路径的目录
+func (s *tcpSink) setLogDir(dir string) {
+	if !filepath.IsAbs(s.config.SpoolDir) {
+		s.spoolDir = filepath.Join(dir, s.config.SpoolDir)
+	}
+}
+
+func (s *tcpSink) Write(entry SinkEntry) error {
+	s.pending = append(s.pending, entry.Line...)
+	if len(entry.Line) == 0 || entry.Line[len(entry.Line)-1] != '\n' {
+		s.pending = append(s.pending, '\n')
+	}
+	if len(s.pending) < s.config.BatchSize {
+		return nil
+	}
+	return s.Flush()
+}
+
+// 按顺序发送spool和等待中的日志，未发送的日志写入spool
+func (s *tcpSink) Flush() error {
+	if s.spool == nil && s.spoolErr == nil {
+		s.spool, s.spoolErr = openSpool(s.spoolDir, s.config.SpoolMaxSize)
+	}
+	if s.spoolErr != nil {
+		// 无法使用spool时只能丢弃
+		s.pending = s.pending[:0]
+		return fmt.Errorf("tcp sink: open spool %s: %w", s.spoolDir, s.spoolErr)
+	}
+	err := s.send()
+	if len(s.pending) > 0 {
+		if werr := s.spool.write(s.pending); werr != nil {
+			err = errors.Join(err, fmt.Errorf("tcp sink: write spool: %w", werr))
+		}
+		s.pending = s.pending[:0]
+	}
+	if s.spool.dropped > s.reported {
+		err = errors.Join(err, fmt.Errorf("tcp sink: spool is full, dropped %d bytes of the oldest entries", s.spool.dropped-s.reported))
+		s.reported = s.spool.dropped
+	}
+	return err
+}
+
+// 连接后先发送spool中的日志，spool为空时才发送pending
+func (s *tcpSink) send() error {
+	if s.conn != nil && !connAlive(s.conn) {
+		// 对端已关闭，重连后重新发送
+		s.closeConn()
+		s.dialer.retryNow()
+	}
+	if s.conn == nil {
+		if !s.dialer.ready() {
+			return nil
+		}
+		conn, err := s.dialer.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	deadline := time.Now().Add(spoolReplayBudget)
+	for !s.spool.empty() {
+		if time.Now().After(deadline) {
+			return nil
+		}
+		data, err := s.spool.oldest()
+		if err == nil {
+			err = s.write(data)
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.spool.removeOldest(); err != nil {
+			return err
+		}
+	}
+	if len(s.pending) == 0 {
+		return nil
+	}
+	if err := s.write(s.pending); err != nil {
+		return err
+	}
+	s.pending = s.pending[:0]
+	return nil
+}
+
+// 写入失败时关闭连接并推迟重连
+func (s *tcpSink) write(data []byte) error {
+	s.conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+	if _, err := s.conn.Write(data); err != nil {
+		s.closeConn()
+		s.dialer.fail()
+		return err
+	}
+	return nil
+}
+
+func (s *tcpSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// 发送剩余的日志，无法发送的留在spool中由下次运行发送
+func (s *tcpSink) Close() error {
+	err := s.Flush()
+	if s.spool != nil {
+		err = errors.Join(err, s.spool.closeFile())
+	}
+	s.closeConn()
+	return err
+}
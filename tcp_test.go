@@ -0,0 +1,174 @@
+package mylog
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func Test_spool(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool(dir, 16<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := bytes.Repeat([]byte("x"), 1023)
+	for i := 0; i < 40; i++ {
+		if err := s.write(append(line, '\n')); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.size > 16<<10 || s.dropped == 0 || s.size+s.dropped != 40<<10 {
+		t.Errorf("size = %d, dropped = %d", s.size, s.dropped)
+	}
+	s.closeFile()
+
+	// 重新打开时按序号加载
+	reopened, err := openSpool(dir, 16<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.segments) != len(s.segments) || reopened.size != s.size || reopened.segments[0].path != s.segments[0].path {
+		t.Errorf("reopened segments = %+v, want %+v", reopened.segments, s.segments)
+	}
+	var total int64
+	for !reopened.empty() {
+		data, err := reopened.oldest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += int64(len(data))
+		reopened.removeOldest()
+	}
+	if total != s.size {
+		t.Errorf("read %d bytes, want %d", total, s.size)
+	}
+}
+
+// 接收并按行返回日志
+func acceptLines(ln net.Listener) <-chan string {
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewScanner(conn)
+				for r.Scan() {
+					lines <- r.Text()
+				}
+			}()
+		}
+	}()
+	return lines
+}
+
+func receive(t *testing.T, lines <-chan string, n int) []string {
+	t.Helper()
+	var got []string
+	for len(got) < n {
+		select {
+		case line := <-lines:
+			got = append(got, line)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %q, want %d lines", got, n)
+		}
+	}
+	return got
+}
+
+func TestTCPSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := acceptLines(ln)
+
+	dir := t.TempDir()
+	sink, err := NewTCPSink(TCPSinkConfig{Addr: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, err := NewLogger(LogConfig{
+		LogDir:     dir,
+		NoConsole:  true,
+		JSONFormat: true,
+		Sinks:      []SinkConfig{{Sink: sink, FlushInterval: 10 * time.Millisecond}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(logger)
+	logger.WithField("user", "tom").Info("first")
+	logger.Warn("second")
+
+	got := receive(t, lines, 2)
+	Sync(logger)
+	file := strings.Split(strings.TrimSpace(readLog(t, filepath.Join(dir, "default.log"))), "\n")
+	if len(file) != 2 || got[0] != file[0] || got[1] != file[1] {
+		t.Errorf("shipped %q, want the lines of the log file %q", got, file)
+	}
+}
+
+func TestTCPSinkSpool(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	dir := t.TempDir()
+	newSink := func() Sink {
+		sink, err := NewTCPSink(TCPSinkConfig{Addr: addr, ReconnectMin: time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink.(*tcpSink).setLogDir(dir)
+		return sink
+	}
+	write := func(sink Sink, msg string) {
+		sink.Write(SinkEntry{Time: time.Now(), Level: logrus.InfoLevel, Line: []byte(msg + "\n")})
+	}
+
+	// 端点不可用时写入spool，关闭后留给下次运行
+	sink := newSink()
+	write(sink, "one")
+	if err := sink.Flush(); err == nil {
+		t.Error("Flush should report the dial error")
+	}
+	write(sink, "two")
+	sink.Close()
+	if matches, _ := filepath.Glob(filepath.Join(dir, "spool", "*"+spoolExt)); len(matches) == 0 {
+		t.Fatal("the entries should be spooled")
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip("can not listen on the same address again:", err)
+	}
+	defer ln.Close()
+	lines := acceptLines(ln)
+	sink = newSink()
+	defer sink.Close()
+	write(sink, "three")
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, lines, 3); strings.Join(got, ",") != "one,two,three" {
+		t.Errorf("received %q, want the spooled entries first", got)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "spool", "*"+spoolExt)); len(matches) != 0 {
+		t.Errorf("spool = %q, want empty", matches)
+	}
+}
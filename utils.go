@@ -2,8 +2,10 @@ package mylog
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -85,7 +87,7 @@ func PraseLevel(level string) logrus.Level {
 		return logrus.DebugLevel
 	case "info":
 		return logrus.InfoLevel
-	case "warn":
+	case "warn", "warning":
 		return logrus.WarnLevel
 	case "error":
 		return logrus.ErrorLevel
@@ -98,6 +100,16 @@ func PraseLevel(level string) logrus.Level {
 	}
 }
 
+// 与PraseLevel相同，但未知的级别返回错误
+func parseLevelStrict(level string) (logrus.Level, error) {
+	switch strings.ToLower(level) {
+	case "trace", "debug", "info", "warn", "warning", "error", "fatal", "panic":
+		return PraseLevel(level), nil
+	default:
+		return logrus.InfoLevel, fmt.Errorf("unknown log level %q (panic, fatal, error, warn, info, debug, trace)", level)
+	}
+}
+
 // 替换文件名中的非法字符为下划线
 func makeFileNameLegal(s string) string {
 	s = strings.ReplaceAll(s, "/", "_")
@@ -187,3 +199,29 @@ func timeStringCompare(time1, time2, format string) int {
 	}
 	return 0
 }
+
+// 解析分段日志文件的序号。
+// base.log -> 0, base.3.log -> 3
+func parseSegmentSeq(fileName, base, ext string) (int, bool) {
+	if fileName == base+ext {
+		return 0, true
+	}
+	if len(fileName) <= len(base)+1+len(ext) ||
+		!strings.HasPrefix(fileName, base+".") || !strings.HasSuffix(fileName, ext) {
+		return 0, false
+	}
+	num := fileName[len(base)+1 : len(fileName)-len(ext)]
+	if num == "" || num[0] == '0' {
+		return 0, false
+	}
+	for _, c := range num {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	seq, err := strconv.Atoi(num)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
@@ -52,3 +52,27 @@ func Test_getShortFileName(t *testing.T) {
 		}
 	}
 }
+
+func Test_parseSegmentSeq(t *testing.T) {
+	tests := []struct {
+		fileName string
+		wantSeq  int
+		wantOk   bool
+	}{
+		{"2024_01_02.log", 0, true},
+		{"2024_01_02.1.log", 1, true},
+		{"2024_01_02.12.log", 12, true},
+		{"2024_01_02.01.log", 0, false},
+		{"2024_01_02.a.log", 0, false},
+		{"2024_01_02..log", 0, false},
+		{"2024_01_02_error.1.log", 0, false},
+		{"2024_01_03.1.log", 0, false},
+		{"2024_01_02.1.txt", 0, false},
+	}
+	for _, tt := range tests {
+		seq, ok := parseSegmentSeq(tt.fileName, "2024_01_02", ".log")
+		if seq != tt.wantSeq || ok != tt.wantOk {
+			t.Errorf("parseSegmentSeq(%q) = %d, %v, want %d, %v", tt.fileName, seq, ok, tt.wantSeq, tt.wantOk)
+		}
+	}
+}
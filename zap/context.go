@@ -0,0 +1,30 @@
+package zap
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/doraemonkeys/mylog"
+	"go.uber.org/zap"
+)
+
+// WithContext returns a child logger with the fields extracted from ctx by the extractors
+// registered in mylog (trace_id, span_id, request_id and the keys added by mylog.RegisterContextKey).
+func WithContext(logger *zap.Logger, ctx context.Context) *zap.Logger {
+	fields := ContextFields(ctx)
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.With(fields...)
+}
+
+// ContextFields returns the fields extracted from ctx by the extractors registered in mylog, sorted by key.
+func ContextFields(ctx context.Context) []zap.Field {
+	values := mylog.ContextFields(ctx)
+	var fields = make([]zap.Field, 0, len(values))
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		fields = append(fields, zap.Any(key, values[key]))
+	}
+	return fields
+}
@@ -0,0 +1,30 @@
+package zap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doraemonkeys/mylog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithContext(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	ctx := mylog.ContextWithTraceID(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736", "")
+	ctx = mylog.ContextWithRequestID(ctx, "req-1")
+	WithContext(logger, ctx).Info("hello")
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields[mylog.TraceIDField] != "4bf92f3577b34da6a3ce929d0e0e4736" || fields[mylog.RequestIDField] != "req-1" {
+		t.Errorf("fields = %v", fields)
+	}
+	if _, ok := fields[mylog.SpanIDField]; ok {
+		t.Errorf("empty span id should be omitted: %v", fields)
+	}
+}
@@ -0,0 +1,87 @@
+package zap
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/doraemonkeys/mylog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Fields adds static fields to every entry, like mylog.LogConfig.Fields.
+func (b *ZapBuilder) Fields(fields map[string]interface{}) *ZapBuilder {
+	if b.fields == nil {
+		b.fields = make(map[string]interface{}, len(fields))
+	}
+	maps.Copy(b.fields, fields)
+	return b
+}
+
+// FieldProviders adds dynamic fields evaluated for every entry, like mylog.LogConfig.FieldProviders,
+// e.g. mylog.DefaultFieldProviders() for hostname and pid.
+func (b *ZapBuilder) FieldProviders(providers map[string]mylog.FieldProvider) *ZapBuilder {
+	if b.fieldProviders == nil {
+		b.fieldProviders = make(map[string]mylog.FieldProvider, len(providers))
+	}
+	maps.Copy(b.fieldProviders, providers)
+	return b
+}
+
+func (b *ZapBuilder) fieldOptions() []zap.Option {
+	if len(b.fields) == 0 {
+		return nil
+	}
+	var fields []zap.Field
+	for _, key := range slices.Sorted(maps.Keys(b.fields)) {
+		fields = append(fields, zap.Any(key, b.fields[key]))
+	}
+	return []zap.Option{zap.Fields(fields...)}
+}
+
+// 包装单个core(不能包装Tee，Tee.Write不检查级别)
+func (b *ZapBuilder) withFieldProviders(core zapcore.Core) zapcore.Core {
+	if len(b.fieldProviders) == 0 {
+		return core
+	}
+	c := &fieldProviderCore{Core: core}
+	for _, key := range slices.Sorted(maps.Keys(b.fieldProviders)) {
+		if b.fieldProviders[key] == nil {
+			continue
+		}
+		c.keys = append(c.keys, key)
+		c.providers = append(c.providers, b.fieldProviders[key])
+	}
+	return c
+}
+
+// 写入时添加动态字段
+type fieldProviderCore struct {
+	zapcore.Core
+	keys      []string
+	providers []mylog.FieldProvider
+}
+
+func (c *fieldProviderCore) With(fields []zapcore.Field) zapcore.Core {
+	return &fieldProviderCore{Core: c.Core.With(fields), keys: c.keys, providers: c.providers}
+}
+
+func (c *fieldProviderCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *fieldProviderCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := make([]zapcore.Field, 0, len(fields)+len(c.keys))
+	all = append(all, fields...)
+	for i, key := range c.keys {
+		// 不覆盖调用时传入的字段
+		if slices.ContainsFunc(fields, func(f zapcore.Field) bool { return f.Key == key }) {
+			continue
+		}
+		all = append(all, zap.Any(key, c.providers[i]()))
+	}
+	return c.Core.Write(ent, all)
+}
@@ -0,0 +1,53 @@
+package zap
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/doraemonkeys/mylog"
+	"go.uber.org/zap"
+)
+
+func TestZapBuilder_Fields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	logger := NewBuilder().LogPath(path).NoConsole().JSONFormatFile().
+		Fields(map[string]interface{}{"service": "api"}).
+		FieldProviders(mylog.DefaultFieldProviders()).
+		Build()
+	logger.Info("hello")
+	logger.Error("oops", zap.Int("pid", 1))
+	_ = logger.Sync()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), content)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	hostname, _ := os.Hostname()
+	if entry["service"] != "api" || entry["hostname"] != hostname || entry["pid"] != float64(os.Getpid()) {
+		t.Errorf("entry = %v", entry)
+	}
+	// 调用时传入的字段不被覆盖
+	if strings.Count(lines[1], `"pid"`) != 1 || !strings.Contains(lines[1], `"pid":1`) {
+		t.Errorf("entry = %s", lines[1])
+	}
+
+	// 错误日志文件只包含错误日志
+	content, err = os.ReadFile(filepath.Join(filepath.Dir(path), "app.error.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "hello") || !strings.Contains(string(content), "oops") {
+		t.Errorf("error log = %q", content)
+	}
+}
@@ -0,0 +1,65 @@
+package zap
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/doraemonkeys/mylog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AtomicLevel returns the level shared by all loggers built by this builder,
+// changing it changes the level of the normal log file and the console at runtime.
+func (b *ZapBuilder) AtomicLevel() zap.AtomicLevel {
+	return b.level
+}
+
+// LevelTarget returns the mylog.LevelTarget of the loggers built by this builder,
+// use it with mylog.NewLevelHandler.
+func (b *ZapBuilder) LevelTarget() mylog.LevelTarget {
+	return zapLevelTarget{b: b}
+}
+
+type zapLevelTarget struct {
+	b *ZapBuilder
+}
+
+func (t zapLevelTarget) Level() string {
+	return t.b.level.Level().String()
+}
+
+func (t zapLevelTarget) SetLevel(level string) error {
+	// zap没有trace级别
+	if strings.EqualFold(level, "trace") {
+		level = "debug"
+	}
+	l, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	t.b.level.SetLevel(l)
+	return nil
+}
+
+func (t zapLevelTarget) ActiveFiles() []string {
+	if t.b.logFileDisable {
+		return nil
+	}
+	var files = []string{t.b.logPath}
+	if !t.b.noErrSeparate {
+		files = append(files, t.b.errorLogPath())
+	}
+	for i := range files {
+		files[i], _ = filepath.Abs(files[i])
+	}
+	return files
+}
+
+func (t zapLevelTarget) SplitMode() string {
+	if t.b.logFileDisable {
+		return "none"
+	}
+	return fmt.Sprintf("size:%d", int64(t.b.maxLogSizeMB)<<20)
+}
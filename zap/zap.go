@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/doraemonkeys/mylog"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -45,6 +46,11 @@ type ZapBuilder struct {
 	stacktraceLevel zapcore.Level
 	// callerSkip
 	callerSkip int
+	// 运行时可修改的级别，由该builder创建的所有logger共享
+	level zap.AtomicLevel
+	// 固定字段和动态字段
+	fields         map[string]interface{}
+	fieldProviders map[string]mylog.FieldProvider
 
 	// enable standard error output
 	// enableStdErr bool
@@ -62,6 +68,7 @@ func NewBuilder() *ZapBuilder {
 		logLevel:        zapcore.InfoLevel,
 		stacktraceLevel: zapcore.FatalLevel,
 		maxLogSizeMB:    100, // lumberjack.Logger default max size
+		level:           zap.NewAtomicLevelAt(zapcore.InfoLevel),
 	}
 }
 
@@ -122,9 +129,10 @@ func (b *ZapBuilder) MaxKeepDays(maxKeepDays int) *ZapBuilder {
 	return b
 }
 
-// Level sets the log level.
+// Level sets the log level, it also changes the level of the loggers already built.
 func (b *ZapBuilder) Level(logLevel zapcore.Level) *ZapBuilder {
 	b.logLevel = logLevel
+	b.level.SetLevel(logLevel)
 	return b
 }
 
@@ -155,10 +163,10 @@ func (b *ZapBuilder) Build() *zap.Logger {
 		return zap.NewNop()
 	}
 	if b.logFileDisable {
-		return b.buildOnlyFile()
+		return b.buildOnlyConsole()
 	}
 	if b.noConsole {
-		return b.buildOnlyConsole()
+		return b.buildOnlyFile()
 	}
 	return b.build()
 }
@@ -174,6 +182,7 @@ func (b *ZapBuilder) build() *zap.Logger {
 			opts = append(opts, zap.AddCallerSkip(b.callerSkip))
 		}
 	}
+	opts = append(opts, b.fieldOptions()...)
 	return zap.New(core, opts...)
 }
 
@@ -188,6 +197,7 @@ func (b *ZapBuilder) buildOnlyConsole() *zap.Logger {
 			opts = append(opts, zap.AddCallerSkip(b.callerSkip))
 		}
 	}
+	opts = append(opts, b.fieldOptions()...)
 	return zap.New(core, opts...)
 }
 
@@ -202,6 +212,7 @@ func (b *ZapBuilder) buildOnlyFile() *zap.Logger {
 			opts = append(opts, zap.AddCallerSkip(b.callerSkip))
 		}
 	}
+	opts = append(opts, b.fieldOptions()...)
 	return zap.New(core, opts...)
 }
 
@@ -249,14 +260,11 @@ func (b *ZapBuilder) buildFileCore() zapcore.Core {
 
 	// If error logging is not separated, return the normal log core directly
 	if b.noErrSeparate {
-		return zapcore.NewCore(encoder, normalWriteSyncer, b.logLevel)
+		return b.withFieldProviders(zapcore.NewCore(encoder, normalWriteSyncer, b.level))
 	}
 
 	// Create error log file
-	logName := strings.TrimSuffix(filepath.Base(b.logPath), filepath.Ext(b.logPath))
-	errorLogPath := filepath.Join(filepath.Dir(b.logPath),
-		logName+".error"+filepath.Ext(b.logPath),
-	)
+	errorLogPath := b.errorLogPath()
 	errorLogWriter := &lumberjack.Logger{
 		Filename:   errorLogPath,
 		MaxSize:    b.maxLogSizeMB, // MB
@@ -268,10 +276,10 @@ func (b *ZapBuilder) buildFileCore() zapcore.Core {
 	errorWriteSyncer := zapcore.AddSync(errorLogWriter)
 
 	// Create error log core, only record errors and above
-	errorCore := zapcore.NewCore(encoder, errorWriteSyncer, zap.ErrorLevel)
+	errorCore := b.withFieldProviders(zapcore.NewCore(encoder, errorWriteSyncer, zap.ErrorLevel))
 
 	// Error log also records in normal log
-	normalCore := zapcore.NewCore(encoder, normalWriteSyncer, b.logLevel)
+	normalCore := b.withFieldProviders(zapcore.NewCore(encoder, normalWriteSyncer, b.level))
 	return zapcore.NewTee(normalCore, errorCore)
 }
 
@@ -313,7 +321,15 @@ func (b *ZapBuilder) buildConsoleCore() zapcore.Core {
 
 	// Create console output
 	consoleWriteSyncer := zapcore.Lock(os.Stdout)
-	return zapcore.NewCore(encoder, consoleWriteSyncer, b.logLevel)
+	return b.withFieldProviders(zapcore.NewCore(encoder, consoleWriteSyncer, b.level))
+}
+
+// name.log -> name.error.log
+func (b *ZapBuilder) errorLogPath() string {
+	logName := strings.TrimSuffix(filepath.Base(b.logPath), filepath.Ext(b.logPath))
+	return filepath.Join(filepath.Dir(b.logPath),
+		logName+".error"+filepath.Ext(b.logPath),
+	)
 }
 
 func (b *ZapBuilder) getTimeEncoder() zapcore.TimeEncoder {
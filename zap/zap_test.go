@@ -0,0 +1,73 @@
+package zap
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// 替换os.Stdout，返回build创建的logger输出到控制台的内容
+func captureStdout(t *testing.T, build func() func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	log := build()
+	os.Stdout = stdout
+	log()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestZapBuilder_Outputs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "console.log")
+	out := captureStdout(t, func() func() {
+		logger := NewBuilder().LogPath(path).NoLogFile().Build()
+		return func() { logger.Info("console only"); _ = logger.Sync() }
+	})
+	if !strings.Contains(out, "console only") {
+		t.Errorf("NoLogFile: console = %q", out)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("NoLogFile should not create %s, err = %v", path, err)
+	}
+
+	path = filepath.Join(dir, "file.log")
+	out = captureStdout(t, func() func() {
+		logger := NewBuilder().LogPath(path).NoConsole().Build()
+		return func() { logger.Info("file only"); _ = logger.Sync() }
+	})
+	if out != "" {
+		t.Errorf("NoConsole: console = %q", out)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(content), "file only") {
+		t.Errorf("NoConsole: file = %q, err = %v", content, err)
+	}
+}
+
+func TestZapBuilder_BuildKeepsLevel(t *testing.T) {
+	b := NewBuilder().NoLogFile().NoConsole()
+	b.Build()
+	if err := b.LevelTarget().SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	// 再次Build不重置运行时修改的级别
+	b.Build()
+	if got := b.AtomicLevel().Level(); got != zapcore.DebugLevel {
+		t.Errorf("level = %v, want debug", got)
+	}
+	b.Level(zapcore.WarnLevel)
+	if got := b.AtomicLevel().Level(); got != zapcore.WarnLevel {
+		t.Errorf("level = %v, want warn", got)
+	}
+}